@@ -0,0 +1,206 @@
+package accesslog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header used to propagate the id of a request to and from lighthouse.
+const RequestIDHeader = "X-Request-ID"
+
+// OpaqueIDHeader is the header elasticsearch uses to tag its tasks and slow logs with the id of the request.
+const OpaqueIDHeader = "X-Opaque-Id"
+
+// Enabled turns on the access log.
+var Enabled = true
+
+// Redacted are the parameters whose values are left out of the access log.
+var Redacted = map[string]bool{"webhook_url": true, "secret": true, "token": true, "password": true}
+
+var logger = &logrus.Logger{
+	Out:       os.Stdout,
+	Formatter: &logrus.JSONFormatter{},
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.InfoLevel,
+}
+
+// entry holds what the handler reports about a request for its access log line.
+type entry struct {
+	mu        sync.Mutex
+	requestID string
+	results   *int
+	cache     string
+	took      *int64
+	params    string
+}
+
+type contextKey struct{}
+
+func fromRequest(r *http.Request) *entry {
+	e, _ := r.Context().Value(contextKey{}).(*entry)
+	return e
+}
+
+// RequestID returns the id of the request, empty if it did not go through the access log handler.
+func RequestID(r *http.Request) string {
+	if e := fromRequest(r); e != nil {
+		return e.requestID
+	}
+	return ""
+}
+
+// SetResults records the number of results returned for the request.
+func SetResults(r *http.Request, count int) {
+	if e := fromRequest(r); e != nil {
+		e.mu.Lock()
+		e.results = &count
+		e.mu.Unlock()
+	}
+}
+
+// SetCache records how the cache served the request.
+func SetCache(r *http.Request, status string) {
+	if e := fromRequest(r); e != nil {
+		e.mu.Lock()
+		e.cache = status
+		e.mu.Unlock()
+	}
+}
+
+// SetParams records the parameters the handler parsed from the body of the request, logged instead of its query. The
+// values of the Redacted parameters are left out.
+func SetParams(r *http.Request, params interface{}) {
+	e := fromRequest(r)
+	if e == nil {
+		return
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	var fields interface{}
+	if json.Unmarshal(b, &fields) != nil {
+		return
+	}
+	b, err = json.Marshal(redact(fields))
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	e.params = string(b)
+	e.mu.Unlock()
+}
+
+// redact replaces the values of the Redacted parameters found in the decoded json value.
+func redact(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if Redacted[k] {
+				value[k] = "redacted"
+			} else {
+				value[k] = redact(v)
+			}
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = redact(v)
+		}
+	}
+	return value
+}
+
+// SetTook records the time in milliseconds elasticsearch reported spending on the request.
+func SetTook(r *http.Request, took int64) {
+	if e := fromRequest(r); e != nil {
+		e.mu.Lock()
+		e.took = &took
+		e.mu.Unlock()
+	}
+}
+
+// Handler assigns each request an id, taken from the X-Request-ID header when present, returns it in the response
+// and writes a JSON access log line for the request once it is served.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		e := &entry{requestID: id}
+		w.Header().Set(RequestIDHeader, id)
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, e))
+		h.ServeHTTP(rec, r)
+		if !Enabled {
+			return
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		fields := logrus.Fields{
+			"request_id": id,
+			"method":     r.Method,
+			"endpoint":   r.URL.Path,
+			"params":     r.URL.Query().Encode(),
+			"status":     rec.status,
+			"bytes":      rec.size,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"remote":     r.RemoteAddr,
+		}
+		if e.results != nil {
+			fields["results"] = *e.results
+		}
+		if e.cache != "" {
+			fields["cache"] = e.cache
+		}
+		if e.took != nil {
+			fields["es_took_ms"] = *e.took
+		}
+		if e.params != "" {
+			fields["params"] = e.params
+		}
+		logger.WithFields(fields).Info("access")
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+type recorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
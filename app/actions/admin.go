@@ -0,0 +1,381 @@
+package actions
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/admin"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/httpcache"
+	"github.com/lbryio/lighthouse/app/jobs/blocked"
+	"github.com/lbryio/lighthouse/app/jobs/chainquery"
+	"github.com/lbryio/lighthouse/app/validator"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+	v "github.com/lbryio/ozzo-validation"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+const maxAdminBody = 1 << 20
+
+// MaxAdminClaims is the largest number of claims that can be deleted or reindexed at once.
+var MaxAdminClaims = 1000
+
+// adminStopper tracks the admin actions running in the background.
+var adminStopper = stop.New()
+
+// SyncRequest is the json body triggering a claim sync. Without a channel or a range it runs the scheduled sync.
+type SyncRequest struct {
+	ChannelID *string `json:"channel_id"`
+	// FromID and ToID bound the Chainquery row ids of the claims indexed, ToID is unbounded if 0.
+	FromID int `json:"from_id"`
+	ToID   int `json:"to_id"`
+}
+
+func (r SyncRequest) validate() error {
+	return v.ValidateStruct(&r,
+		v.Field(&r.ChannelID, v.Length(claimIDLength, claimIDLength), validator.ClaimIDsValidator),
+		v.Field(&r.FromID, v.Min(0)),
+		v.Field(&r.ToID, v.Min(0)),
+	)
+}
+
+// ResetRequest is the json body resetting the sync state.
+type ResetRequest struct {
+	// Sync starts the full sync right away instead of at the next scheduled run.
+	Sync bool `json:"sync"`
+}
+
+// ClaimIDsRequest is the json body listing the claims to delete or reindex.
+type ClaimIDsRequest struct {
+	ClaimIDs []string `json:"claim_ids"`
+}
+
+func (r ClaimIDsRequest) validate() error {
+	err := v.ValidateStruct(&r, v.Field(&r.ClaimIDs, v.Required, v.Length(1, MaxAdminClaims)))
+	if err != nil {
+		return err
+	}
+	for _, id := range r.ClaimIDs {
+		if len(id) != claimIDLength || validator.ClaimIDsValidator.Validate(id) != nil {
+			return errors.Err("claim_ids: invalid claim id %q, must be 40 hexadecimal characters", id)
+		}
+	}
+	return nil
+}
+
+// BlockedRequest is the json body running the blocked list jobs.
+type BlockedRequest struct {
+	// List is blocked or filtered, both lists are processed if it is empty.
+	List string `json:"list"`
+}
+
+func (r BlockedRequest) validate() error {
+	return v.ValidateStruct(&r, v.Field(&r.List, v.In("blocked", "filtered")))
+}
+
+// LogLevelRequest is the json body changing the log level.
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// ClaimsResult lists the claims affected by a delete or a reindex.
+type ClaimsResult struct {
+	Indexed []string `json:"indexed"`
+	Removed []string `json:"removed"`
+}
+
+type auditRequest struct {
+	Size *int
+}
+
+func (r *auditRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.Size, v.Min(1), v.Max(1000)),
+	}
+}
+
+type adminAction struct {
+	method string
+	// params are the query parameters of the action and their rules, none if nil.
+	params func() (interface{}, []*v.FieldRules)
+	// body is a pointer to the json body of the action, nil if it takes none.
+	body func() interface{}
+	run  func(r *http.Request, actor string, params, body interface{}) api.Response
+}
+
+// adminActions are the actions of the admin api by path and method.
+var adminActions = map[string][]adminAction{
+	"/admin/sync": {
+		{method: http.MethodGet, run: syncState},
+		{method: http.MethodPost, body: func() interface{} { return &SyncRequest{} }, run: startSync},
+	},
+	"/admin/sync/reset": {
+		{method: http.MethodPost, body: func() interface{} { return &ResetRequest{} }, run: resetSync},
+	},
+	"/admin/claims/delete": {
+		{method: http.MethodPost, body: func() interface{} { return &ClaimIDsRequest{} }, run: deleteClaims},
+	},
+	"/admin/claims/reindex": {
+		{method: http.MethodPost, body: func() interface{} { return &ClaimIDsRequest{} }, run: reindexClaims},
+	},
+	"/admin/blocked": {
+		{method: http.MethodPost, body: func() interface{} { return &BlockedRequest{} }, run: runBlocked},
+	},
+	"/admin/loglevel": {
+		{method: http.MethodGet, run: logLevel},
+		{method: http.MethodPost, body: func() interface{} { return &LogLevelRequest{} }, run: setLogLevel},
+	},
+	"/admin/audit": {
+		{method: http.MethodGet, params: func() (interface{}, []*v.FieldRules) {
+			r := &auditRequest{}
+			return r, r.rules()
+		}, run: auditLog},
+	},
+}
+
+// Admin serves the admin api. Requests are authorized by an admin token, presented as `Authorization: Bearer
+// <token>`, or by a client certificate on the mutual TLS admin listener. Every action, including refused ones, is
+// recorded in the audit log.
+func Admin(r *http.Request) api.Response {
+	path := strings.TrimRight(r.URL.Path, "/")
+	action := r.Method + " " + path
+	// Requests are authorized, and refused ones audited, before anything of them is parsed.
+	actor, ok := admin.Authorize(r)
+	if !ok {
+		err := errors.Err("the admin api requires an admin token or client certificate")
+		admin.Audit(r, "", action, nil, admin.OutcomeError, err)
+		return api.Response{Error: err, Status: http.StatusUnauthorized}
+	}
+	a, known := findAdminAction(path, r.Method)
+	params, rules := interface{}(&noParams{}), []*v.FieldRules(nil)
+	if a != nil && a.params != nil {
+		params, rules = a.params()
+	}
+	err := queryValues(r, params, rules)
+	if err != nil {
+		err = errors.Err(err)
+		admin.Audit(r, actor, action, nil, admin.OutcomeError, err)
+		return api.Response{Error: err, Status: http.StatusBadRequest}
+	}
+	if !known {
+		return api.Response{Error: errors.Err("admin action %s not found", path), Status: http.StatusNotFound}
+	}
+	if a == nil {
+		return api.Response{Error: errors.Err("%s does not support %s", path, r.Method), Status: http.StatusMethodNotAllowed}
+	}
+	var body interface{}
+	if a.body != nil {
+		body = a.body()
+		d := json.NewDecoder(io.LimitReader(r.Body, maxAdminBody))
+		d.DisallowUnknownFields()
+		err := d.Decode(body)
+		if err != nil && err != io.EOF {
+			err = errors.Err("invalid request body: %s", err)
+			admin.Audit(r, actor, action, nil, admin.OutcomeError, err)
+			return api.Response{Error: err, Status: http.StatusBadRequest}
+		}
+		accesslog.SetParams(r, body)
+		if b, ok := body.(interface{ validate() error }); ok {
+			if err := b.validate(); err != nil {
+				admin.Audit(r, actor, action, body, admin.OutcomeError, err)
+				return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+			}
+		}
+	}
+	rsp := a.run(r, actor, params, body)
+	outcome := admin.OutcomeSuccess
+	if rsp.Status == http.StatusAccepted {
+		outcome = admin.OutcomeStarted
+	}
+	admin.Audit(r, actor, action, body, outcome, rsp.Error)
+	return rsp
+}
+
+// findAdminAction returns the action of the path for the method, nil if there is none, and whether the path exists.
+func findAdminAction(path, method string) (*adminAction, bool) {
+	actions, ok := adminActions[path]
+	for i := range actions {
+		if actions[i].method == method {
+			return &actions[i], true
+		}
+	}
+	return nil, ok
+}
+
+func syncState(r *http.Request, _ string, _, _ interface{}) api.Response {
+	state, err := chainquery.GetSyncState(r.Context())
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	return api.Response{Data: state}
+}
+
+// startSync runs the sync in the background, its completion is recorded in the audit log.
+func startSync(r *http.Request, actor string, _, body interface{}) api.Response {
+	req := body.(*SyncRequest)
+	ranged := req.ChannelID != nil || req.FromID > 0 || req.ToID > 0
+	if req.ToID > 0 && req.ToID <= req.FromID {
+		return api.Response{Error: errors.Err("to_id: must be greater than from_id"), Status: http.StatusBadRequest}
+	}
+	if req.ChannelID != nil {
+		id := strings.ToLower(*req.ChannelID)
+		req.ChannelID = &id
+	}
+	// The sync is reserved before responding so that a conflict is reported instead of failing in the background.
+	var run func() error
+	var err error
+	if ranged {
+		run, err = chainquery.StartSyncRange(req.ChannelID, req.FromID, req.ToID)
+	} else {
+		run, err = chainquery.StartSync(nil)
+	}
+	if errors.Is(err, chainquery.ErrSyncRunning) {
+		return api.Response{Error: err, Status: http.StatusConflict}
+	}
+	if errors.Is(err, chainquery.ErrUnsupported) {
+		return api.Response{Error: err, Status: http.StatusBadRequest}
+	}
+	if err != nil {
+		return api.Response{Error: err, Status: http.StatusServiceUnavailable}
+	}
+	background(r, actor, "sync", req, run)
+	return api.Response{Data: "sync started", Status: http.StatusAccepted}
+}
+
+func resetSync(r *http.Request, actor string, _, body interface{}) api.Response {
+	req := body.(*ResetRequest)
+	err := chainquery.ResetSyncState()
+	if errors.Is(err, chainquery.ErrSyncRunning) {
+		return api.Response{Error: err, Status: http.StatusConflict}
+	}
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	if req.Sync {
+		run, err := chainquery.StartSync(nil)
+		if errors.Is(err, chainquery.ErrSyncRunning) {
+			// It started after the reset, so it indexes every claim.
+			return api.Response{Data: "sync state reset, the sync that started meanwhile indexes every claim"}
+		}
+		if err != nil {
+			return api.Response{Error: err, Status: http.StatusServiceUnavailable}
+		}
+		background(r, actor, "sync", req, run)
+		return api.Response{Data: "sync state reset, full sync started", Status: http.StatusAccepted}
+	}
+	return api.Response{Data: "sync state reset, the next sync indexes every claim"}
+}
+
+func deleteClaims(r *http.Request, _ string, _, body interface{}) api.Response {
+	req := body.(*ClaimIDsRequest)
+	service := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	for _, id := range req.ClaimIDs {
+		service.Add(elastic.NewBulkDeleteRequest().Id(strings.ToLower(id)))
+	}
+	res, err := service.Do(r.Context())
+	if err != nil {
+		return api.Response{Error: errors.Err(err)}
+	}
+	result := ClaimsResult{Indexed: []string{}, Removed: []string{}}
+	for _, item := range res.Deleted() {
+		if item.Status == http.StatusOK {
+			result.Removed = append(result.Removed, item.Id)
+		}
+	}
+	httpcache.PurgeClaims(result.Removed...)
+	return api.Response{Data: result}
+}
+
+func reindexClaims(r *http.Request, _ string, _, body interface{}) api.Response {
+	req := body.(*ClaimIDsRequest)
+	ids := make([]string, len(req.ClaimIDs))
+	for i, id := range req.ClaimIDs {
+		ids[i] = strings.ToLower(id)
+	}
+	indexed, removed, err := chainquery.Reindex(r.Context(), ids)
+	if errors.Is(err, chainquery.ErrUnsupported) {
+		return api.Response{Error: err, Status: http.StatusBadRequest}
+	}
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	result := ClaimsResult{Indexed: indexed, Removed: removed}
+	if result.Indexed == nil {
+		result.Indexed = []string{}
+	}
+	if result.Removed == nil {
+		result.Removed = []string{}
+	}
+	return api.Response{Data: result}
+}
+
+// runBlocked processes the blocked lists in the background, their completion is recorded in the audit log.
+func runBlocked(r *http.Request, actor string, _, body interface{}) api.Response {
+	req := body.(*BlockedRequest)
+	background(r, actor, "blocked", req, func() error {
+		if req.List != "filtered" {
+			err := blocked.ProcessBlockedList()
+			if err != nil {
+				return err
+			}
+		}
+		if req.List != "blocked" {
+			return blocked.ProcessFilteredList()
+		}
+		return nil
+	})
+	return api.Response{Data: "blocked list processing started", Status: http.StatusAccepted}
+}
+
+func logLevel(_ *http.Request, _ string, _, _ interface{}) api.Response {
+	return api.Response{Data: LogLevelRequest{Level: logrus.GetLevel().String()}}
+}
+
+func setLogLevel(_ *http.Request, _ string, _, body interface{}) api.Response {
+	req := body.(*LogLevelRequest)
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+	}
+	logrus.SetLevel(level)
+	logrus.Infof("log level set to %s", level)
+	return api.Response{Data: LogLevelRequest{Level: level.String()}}
+}
+
+func auditLog(r *http.Request, _ string, params, _ interface{}) api.Response {
+	req := params.(*auditRequest)
+	size := 100
+	if req.Size != nil {
+		size = *req.Size
+	}
+	entries, err := admin.Entries(r.Context(), size)
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	return api.Response{Data: entries}
+}
+
+// ShutdownAdmin waits for the admin actions running in the background to finish and record their outcome. The
+// background jobs are shut down first, so that the syncs started by admins stop at their next checkpoint.
+func ShutdownAdmin() {
+	adminStopper.StopAndWait()
+}
+
+// background runs a long admin action after the response is sent, recording its outcome in the audit log.
+func background(r *http.Request, actor, action string, params interface{}, run func() error) {
+	adminStopper.Add(1)
+	go func() {
+		defer adminStopper.Done()
+		err := run()
+		admin.Audit(r, actor, action+" finished", params, admin.OutcomeSuccess, err)
+	}()
+}
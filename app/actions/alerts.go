@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/alerts"
+	"github.com/lbryio/lighthouse/app/auth"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	v "github.com/lbryio/ozzo-validation"
+)
+
+const maxSavedSearchBody = 1 << 16
+
+var savedSearchPath = regexp.MustCompile(`^/alerts/([0-9a-f]+)(/deliveries)?/?$`)
+
+// savedSearchRequest is the json body registering a saved search.
+type savedSearchRequest struct {
+	Name string `json:"name"`
+	// Params are the parameters of the search, as sent to /search.
+	Params     map[string]string `json:"params"`
+	WebhookURL string            `json:"webhook_url"`
+}
+
+type noParams struct{}
+
+// queryValues parses the query parameters of a request taking a json body into params, as api.FormValues does. The
+// body is left unread whatever its content type, as clients such as curl post json as a form by default.
+func queryValues(r *http.Request, params interface{}, rules []*v.FieldRules) error {
+	r.Form = r.URL.Query()
+	r.PostForm = url.Values{}
+	return api.FormValues(r, params, rules)
+}
+
+type deliveriesRequest struct {
+	Size *int
+}
+
+func (r *deliveriesRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.Size, v.Min(1), v.Max(500)),
+	}
+}
+
+// SavedSearches lists the searches saved with the API key of the request on GET, and saves a search on POST. The
+// newly indexed claims matching a saved search are posted to its webhook, signed with the secret returned when it
+// is saved. Internal API keys list every saved search.
+func SavedSearches(r *http.Request) api.Response {
+	err := queryValues(r, &noParams{}, nil)
+	if err != nil {
+		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+	}
+	caller, err := alertsCaller(r)
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		owner := caller.Name
+		if caller.Tier == auth.Tiers["internal"] {
+			owner = ""
+		}
+		searches, err := alerts.List(r.Context(), owner)
+		if err != nil {
+			return api.Response{Error: err}
+		}
+		if searches == nil {
+			searches = []alerts.SavedSearch{}
+		}
+		return api.Response{Data: searches}
+	case http.MethodPost:
+		req := savedSearchRequest{}
+		d := json.NewDecoder(io.LimitReader(r.Body, maxSavedSearchBody))
+		d.DisallowUnknownFields()
+		err := d.Decode(&req)
+		if err != nil {
+			return api.Response{Error: errors.Err("invalid request body: %s", err), Status: http.StatusBadRequest}
+		}
+		accesslog.SetParams(r, req)
+		saved, err := alerts.Register(r.Context(), alerts.SavedSearch{
+			Name:       req.Name,
+			Owner:      caller.Name,
+			Params:     req.Params,
+			WebhookURL: req.WebhookURL,
+		})
+		if err != nil {
+			return api.Response{Error: errors.Err(err)}
+		}
+		return api.Response{Data: saved, Status: http.StatusCreated}
+	}
+	return api.Response{Error: errors.Err("saved searches are listed with GET and saved with POST"),
+		Status: http.StatusMethodNotAllowed}
+}
+
+// SavedSearch returns the saved search with the id in the path, /alerts/{id}, on GET and deletes it on DELETE.
+// /alerts/{id}/deliveries returns its latest deliveries, newest first, with the attempts made for each.
+func SavedSearch(r *http.Request) api.Response {
+	req := deliveriesRequest{}
+	err := api.FormValues(r, &req, req.rules())
+	if err != nil {
+		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+	}
+	caller, err := alertsCaller(r)
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	match := savedSearchPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		return api.Response{Error: errors.Err("saved search not found"), Status: http.StatusNotFound}
+	}
+	saved, ok, err := alerts.Get(r.Context(), match[1])
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	if !ok || (saved.Owner != caller.Name && caller.Tier != auth.Tiers["internal"]) {
+		return api.Response{Error: errors.Err("saved search %s not found", match[1]), Status: http.StatusNotFound}
+	}
+	saved.Secret = ""
+	if match[2] != "" {
+		if r.Method != http.MethodGet {
+			return api.Response{Error: errors.Err("deliveries are listed with GET"), Status: http.StatusMethodNotAllowed}
+		}
+		size := 20
+		if req.Size != nil {
+			size = *req.Size
+		}
+		deliveries, err := alerts.DeliveryLog(r.Context(), saved.ID, size)
+		if err != nil {
+			return api.Response{Error: err}
+		}
+		return api.Response{Data: deliveries}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return api.Response{Data: saved}
+	case http.MethodDelete:
+		err := alerts.Delete(r.Context(), saved.ID)
+		if err != nil {
+			return api.Response{Error: err}
+		}
+		return api.Response{Data: saved}
+	}
+	return api.Response{Error: errors.Err("saved searches are read with GET and deleted with DELETE"),
+		Status: http.StatusMethodNotAllowed}
+}
+
+func alertsCaller(r *http.Request) (auth.Caller, error) {
+	caller := auth.FromRequest(r)
+	if caller.Name == "" {
+		return caller, api.StatusError{Status: http.StatusUnauthorized, Err: errors.Err("saved searches require an api key")}
+	}
+	return caller, nil
+}
@@ -7,14 +7,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/cache"
 	"github.com/lbryio/lighthouse/app/es"
 	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/timeout"
 
 	"github.com/lbryio/lbry.go/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/api"
 	v "github.com/lbryio/ozzo-validation"
 
-	"github.com/karlseguin/ccache"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/olivere/elastic.v6"
 )
@@ -25,24 +29,33 @@ type autoCompleteRequest struct {
 	From *int
 	NSFW *bool
 	//Debug params
-	Source *bool
-	Debug  *bool
+	Source bool
+	Debug  bool
 }
 
-var autoCompleteCache = ccache.New(ccache.Configure().MaxSize(10000))
+func (r *autoCompleteRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.S, v.Required, v.Length(1, 0)),
+		v.Field(&r.Size, v.Max(10000)),
+		v.Field(&r.From, v.Max(9999)),
+	}
+}
+
+var autoCompleteCache = cache.New("autocomplete", 10000, 5*time.Minute, 10*time.Minute)
 
 // AutoComplete returns the name of claims that it matches against for auto completion.
 func AutoComplete(r *http.Request) api.Response {
 	acRequest := autoCompleteRequest{}
 	start := time.Now()
-	err := api.FormValues(r, &acRequest, []*v.FieldRules{
-		v.Field(&acRequest.S, v.Required, v.Length(1, 0)),
-		v.Field(&acRequest.Size, v.Max(10000)),
-		v.Field(&acRequest.From, v.Max(9999)),
-	})
+	err := api.FormValues(r, &acRequest, acRequest.rules())
 	if err != nil {
 		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
 	}
+	caller := auth.FromRequest(r)
+	err = caller.CheckLimits(acRequest.Size, acRequest.From, acRequest.Debug || acRequest.Source)
+	if err != nil {
+		return api.Response{Error: errors.Err(err)}
+	}
 	replacer := strings.NewReplacer("/", "\\/", "[", "\\[", "]", "\\]")
 	acRequest.S = replacer.Replace(acRequest.S)
 
@@ -71,6 +84,7 @@ func AutoComplete(r *http.Request) api.Response {
 	}
 
 	if acRequest.NSFW != nil {
+		metrics.FilterUsage.WithLabelValues("nsfw").Inc()
 		query = query.Must(elastic.NewMatchQuery("nsfw", *acRequest.NSFW))
 	}
 
@@ -79,32 +93,55 @@ func AutoComplete(r *http.Request) api.Response {
 		return api.Response{Error: errors.Err("%s: for query -s %s", err, t)}
 	}
 	sourceContext := elastic.NewFetchSourceContext(true)
-	if acRequest.Source == nil {
+	if !acRequest.Source {
 		sourceContext = sourceContext.Include("name", "claimId")
 	}
 	service := es.Client.
 		Search("claims").
 		Query(query).
-		FetchSourceContext(sourceContext)
+		FetchSourceContext(sourceContext).
+		Header(accesslog.OpaqueIDHeader, accesslog.RequestID(r))
 	if acRequest.Size != nil {
 		service = service.Size(*acRequest.Size)
 	}
 	if acRequest.From != nil {
 		service = service.From(*acRequest.From)
 	}
+	if shards, ok := timeout.Shards(r.Context()); ok {
+		service = service.Timeout(shards)
+	}
 
-	if acRequest.Debug != nil {
-		searchResults, err := service.Explain(true).Do(context.Background())
+	if acRequest.Debug {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return api.Response{Error: breaker.Unavailable("elasticsearch")}
+		}
+		esStart := time.Now()
+		searchResults, err := service.Explain(true).Do(r.Context())
+		es.Breaker.Record(time.Since(esStart), err)
 		if err != nil {
-			return api.Response{Error: errors.Err(err)}
+			return api.Response{Error: errors.Err(timeout.Error(err))}
 		}
+		if partial, timedOut := es.Partial(searchResults); partial {
+			timeout.SetPartial(r, timedOut)
+		}
+		accesslog.SetTook(r, searchResults.TookInMillis)
+		accesslog.SetResults(r, len(searchResults.Hits.Hits))
 		return api.Response{Data: searchResults}
 	}
-	results, err := autoCompleteCache.Fetch(r.URL.RequestURI(), 5*time.Minute, func() (interface{}, error) {
-		searchResults, err := service.Do(context.Background())
+	key := cache.Key(r, "s")
+	value, cacheStatus, err := autoCompleteCache.Fetch(r.Context(), key, func(ctx context.Context) (interface{}, error) {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+		esStart := time.Now()
+		searchResults, err := service.Do(ctx)
+		es.Breaker.Record(time.Since(esStart), err)
 		if err != nil {
 			return nil, errors.Err(err)
 		}
+		metrics.ES(esStart, "autocomplete", searchResults.TookInMillis, searchResults.Hits.TotalHits)
 		type lighthouseResult struct {
 			Name string `json:"name"`
 		}
@@ -129,12 +166,43 @@ func AutoComplete(r *http.Request) api.Response {
 				}
 			}
 		}
-		return names, nil
+		partial, timedOut := es.Partial(searchResults)
+		if partial {
+			metrics.Incomplete("autocomplete", timedOut)
+			return cache.NoStore(autoCompleteResult{names: names, took: searchResults.TookInMillis, partial: partial,
+				timedOut: timedOut}), nil
+		}
+		return autoCompleteResult{names: names, took: searchResults.TookInMillis}, nil
 	})
+	served := string(cacheStatus)
+	if errors.Is(err, breaker.ErrOpen) {
+		stale, ok := autoCompleteCache.Peek(key)
+		value, err = breaker.Stale(r, "elasticsearch", "autocomplete", stale, ok)
+		served = "degraded"
+	}
 	if err != nil {
-		return api.Response{Error: errors.Err(err)}
+		return api.Response{Error: errors.Err(timeout.Error(err))}
+	}
+	results := value.(autoCompleteResult)
+	if results.partial {
+		timeout.SetPartial(r, results.timedOut)
 	}
+	returned := len(results.names)
+	accesslog.SetTook(r, results.took)
+	accesslog.SetCache(r, served)
+	accesslog.SetResults(r, returned)
+	metrics.Results("autocomplete", len(strings.Fields(acRequest.S)), returned)
 	metrics.AutoCompleteDuration.Observe(time.Since(start).Seconds())
-	return api.Response{Data: results.Value()}
+	return api.Response{Data: results.names}
+
+}
 
+// autoCompleteResult is the cached result of an auto completion. Results of searches that did not complete on every
+// shard are returned but not cached.
+type autoCompleteResult struct {
+	names []string
+	// took is the time elasticsearch spent on the search the names were fetched with.
+	took     int64
+	partial  bool
+	timedOut bool
 }
@@ -0,0 +1,269 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/cache"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/httpcache"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/jobs/blocked"
+	"github.com/lbryio/lighthouse/app/model"
+	"github.com/lbryio/lighthouse/app/timeout"
+	"github.com/lbryio/lighthouse/app/validator"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	v "github.com/lbryio/ozzo-validation"
+
+	"gopkg.in/olivere/elastic.v6"
+)
+
+// MaxClaimIDs is the largest number of claim ids that can be looked up at once.
+var MaxClaimIDs = 100
+
+const claimIDLength = 40
+
+var claimCache = cache.New("claim", 10000, 5*time.Minute, 10*time.Minute)
+
+type claimRequest struct {
+	Fields *string
+}
+
+func (r *claimRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.Fields, v.Length(1, 0)),
+	}
+}
+
+type claimsRequest struct {
+	IDs    string `json:"ids"`
+	Fields *string
+}
+
+func (r *claimsRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.IDs, v.Required, validator.ClaimIDsValidator),
+		v.Field(&r.Fields, v.Length(1, 0)),
+	}
+}
+
+type claimsResult struct {
+	Claims []model.Claim `json:"claims"`
+	// Missing holds the requested ids that are unknown, blocked, or prefixes matching more than one claim.
+	Missing []string `json:"missing"`
+}
+
+// Claim returns the indexed document of the claim with the claim id in the path, /claim/{claim_id}. The claim id can
+// be shortened to a prefix as long as it matches a single claim. The fields parameter selects the fields returned as
+// a comma separated list. Unknown and blocked claims are answered with 404.
+func Claim(r *http.Request) api.Response {
+	req := claimRequest{}
+	err := api.FormValues(r, &req, req.rules())
+	if err != nil {
+		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+	}
+	id := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, "/claim/"), "/"))
+	if err := validator.ClaimIDsValidator.Validate(id); err != nil || id == "" || strings.Contains(id, ",") {
+		return api.Response{Error: errors.Err("invalid claim id %q, must be up to 40 hexadecimal characters", id),
+			Status: http.StatusBadRequest}
+	}
+	claims, err := lookupClaims(r, []string{id}, req.Fields)
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	if len(claims.Claims) == 0 {
+		return api.Response{Error: errors.Err("claim %s not found", id), Status: http.StatusNotFound}
+	}
+	return api.Response{Data: claims.Claims[0]}
+}
+
+// Claims returns the indexed documents of the claims with the comma separated claim ids, or claim id prefixes, of the
+// ids parameter in the order they were requested. Unknown and blocked claims are listed as missing.
+func Claims(r *http.Request) api.Response {
+	req := claimsRequest{}
+	err := api.FormValues(r, &req, req.rules())
+	if err != nil {
+		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(req.IDs, ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > MaxClaimIDs {
+		return api.Response{Error: errors.Err("ids: at most %d claim ids can be looked up at once", MaxClaimIDs),
+			Status: http.StatusBadRequest}
+	}
+	claims, err := lookupClaims(r, ids, req.Fields)
+	if err != nil {
+		return api.Response{Error: err}
+	}
+	return api.Response{Data: claims}
+}
+
+// lookupClaims gets the claims with full claim ids in a single get or multi get, and resolves the prefixes in a
+// single multi search. Results are cached like searches as they are expected to be requested repeatedly.
+func lookupClaims(r *http.Request, ids []string, fields *string) (claimsResult, error) {
+	source := elastic.NewFetchSourceContext(true)
+	selected := map[string]bool{}
+	if fields != nil {
+		// The claim id is always returned, the channel is needed to check whether it is blocked.
+		includes := []string{"claimId", "channel_claim_id"}
+		for _, f := range strings.Split(*fields, ",") {
+			f = strings.TrimSpace(f)
+			selected[f] = true
+			includes = append(includes, f)
+		}
+		source = source.Include(includes...)
+	}
+	key := cache.Key(r)
+	value, cacheStatus, err := claimCache.Fetch(r.Context(), key, func(ctx context.Context) (interface{}, error) {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+		esStart := time.Now()
+		found, err := getClaims(ctx, ids, source)
+		es.Breaker.Record(time.Since(esStart), err)
+		if err != nil {
+			return nil, err
+		}
+		metrics.ESDuration.WithLabelValues("claim").Observe(time.Since(esStart).Seconds())
+		return found, nil
+	})
+	served := string(cacheStatus)
+	if errors.Is(err, breaker.ErrOpen) {
+		stale, ok := claimCache.Peek(key)
+		value, err = breaker.Stale(r, "elasticsearch", "claim", stale, ok)
+		served = "degraded"
+	}
+	if err != nil {
+		return claimsResult{}, errors.Err(timeout.Error(err))
+	}
+	found := value.(map[string]model.Claim)
+	result := claimsResult{Claims: make([]model.Claim, 0, len(ids)), Missing: make([]string, 0)}
+	for _, id := range ids {
+		claim, ok := found[id]
+		if !ok || blocked.IsBlocked(claim.ClaimID, channelID(claim)) {
+			result.Missing = append(result.Missing, id)
+			continue
+		}
+		if fields != nil && !selected["channel_claim_id"] {
+			claim.ChannelClaimID = nil
+		}
+		httpcache.AddClaims(r, claim.ClaimID)
+		result.Claims = append(result.Claims, claim)
+	}
+	accesslog.SetCache(r, served)
+	accesslog.SetResults(r, len(result.Claims))
+	return result, nil
+}
+
+// getClaims returns the claims by the id or prefix they were requested with. Prefixes matching more than one claim
+// are left out.
+func getClaims(ctx context.Context, ids []string, source *elastic.FetchSourceContext) (map[string]model.Claim, error) {
+	found := make(map[string]model.Claim)
+	var full, prefixes []string
+	for _, id := range ids {
+		if len(id) == claimIDLength {
+			full = append(full, id)
+		} else {
+			prefixes = append(prefixes, id)
+		}
+	}
+	if len(full) == 1 {
+		res, err := es.Client.Get().Index(index.Claims).Type(index.ClaimType).Id(full[0]).FetchSourceContext(source).Do(ctx)
+		if err != nil && !elastic.IsNotFound(err) {
+			return nil, errors.Err(err)
+		}
+		if err == nil && res.Found && res.Source != nil {
+			claim, err := decodeClaim(*res.Source)
+			if err != nil {
+				return nil, err
+			}
+			found[full[0]] = claim
+		}
+	} else if len(full) > 1 {
+		service := es.Client.MultiGet()
+		for _, id := range full {
+			service.Add(elastic.NewMultiGetItem().Index(index.Claims).Type(index.ClaimType).Id(id).FetchSource(source))
+		}
+		res, err := service.Do(ctx)
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		for _, doc := range res.Docs {
+			if !doc.Found || doc.Source == nil {
+				continue
+			}
+			claim, err := decodeClaim(*doc.Source)
+			if err != nil {
+				return nil, err
+			}
+			found[doc.Id] = claim
+		}
+	}
+	if len(prefixes) > 0 {
+		// Fetch up to two claims per prefix to tell the ambiguous ones apart.
+		service := es.Client.MultiSearch().Index(index.Claims)
+		for _, prefix := range prefixes {
+			service.Add(elastic.NewSearchRequest().Source(elastic.NewSearchSource().
+				Query(elastic.NewPrefixQuery("claimId.keyword", prefix)).
+				FetchSourceContext(source).
+				Size(2)))
+		}
+		res, err := service.Do(ctx)
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		for i, result := range res.Responses {
+			if i >= len(prefixes) || result == nil {
+				continue
+			}
+			if result.Error != nil {
+				return nil, errors.Err(result.Error.Reason)
+			}
+			if result.Hits == nil || len(result.Hits.Hits) != 1 {
+				continue
+			}
+			hit := result.Hits.Hits[0]
+			if hit.Source == nil {
+				continue
+			}
+			claim, err := decodeClaim(*hit.Source)
+			if err != nil {
+				return nil, err
+			}
+			found[prefixes[i]] = claim
+		}
+	}
+	return found, nil
+}
+
+func decodeClaim(source json.RawMessage) (model.Claim, error) {
+	var claim model.Claim
+	err := json.Unmarshal(source, &claim)
+	if err != nil {
+		return claim, errors.Err(err)
+	}
+	return claim, nil
+}
+
+func channelID(claim model.Claim) string {
+	if claim.ChannelClaimID == nil {
+		return ""
+	}
+	return claim.ChannelClaimID.String
+}
@@ -0,0 +1,418 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/actions/search"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/openapi"
+	"github.com/lbryio/lighthouse/app/ratelimit"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	v "github.com/lbryio/ozzo-validation"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxGraphQLBody is the largest request body accepted by the graphql endpoint.
+	maxGraphQLBody = 1 << 20
+	// maxGraphQLSearches is the number of searches, autocompletions and related content searches included, a single
+	// graphql query can make.
+	maxGraphQLSearches = 50
+	relatedSize        = 10
+)
+
+// claimSources maps the fields of the graphql Claim type to the fields of the claim documents they are read from.
+var claimSources = []struct {
+	field  string
+	source string
+	typ    graphql.Output
+}{
+	{"claimId", "claimId", graphql.String},
+	{"name", "name", graphql.String},
+	{"title", "title", graphql.String},
+	{"description", "description", graphql.String},
+	{"thumbnailUrl", "thumbnail_url", graphql.String},
+	{"releaseTime", "release_time", graphql.String},
+	{"transactionTime", "transaction_time", graphql.String},
+	{"contentType", "content_type", graphql.String},
+	{"claimType", "claim_type", graphql.String},
+	{"duration", "duration", graphql.Int},
+	{"nsfw", "nsfw", graphql.Boolean},
+	{"fee", "fee", graphql.Float},
+	{"tags", "tags", graphql.NewList(graphql.String)},
+	{"viewCount", "view_cnt", graphql.Int},
+	{"subCount", "sub_cnt", graphql.Int},
+	{"claimCount", "claim_cnt", graphql.Int},
+	{"effectiveAmount", "effective_amount", graphql.Float},
+	{"certValid", "cert_valid", graphql.Boolean},
+	{"channelName", "channel", graphql.String},
+	{"channelClaimId", "channel_claim_id", graphql.String},
+}
+
+// graphQLRequest is the body of a graphql query.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLKey struct{}
+
+// graphQLContext holds what the resolvers of a query share, the request it was sent with and the loader that batches
+// the claim lookups.
+type graphQLContext struct {
+	r        *http.Request
+	loader   *es.Loader
+	searches int32
+}
+
+func fromContext(ctx context.Context) *graphQLContext {
+	return ctx.Value(graphQLKey{}).(*graphQLContext)
+}
+
+// request returns a copy of the graphql request for the api route with the parameters, so that the resolvers go
+// through the same validation, limits and caches as the rest of the api. Each request counts towards the searches
+// of the query and is charged to the rate limit of the client like a request to the route, as a query can alias a
+// field many times.
+func (g *graphQLContext) request(path string, params url.Values) (*http.Request, error) {
+	if atomic.AddInt32(&g.searches, 1) > maxGraphQLSearches {
+		return nil, errors.Err("a query can search at most %d times", maxGraphQLSearches)
+	}
+	size, _ := strconv.Atoi(params.Get("size"))
+	allowed, retryAfter := ratelimit.Allow(auth.FromRequest(g.r), ratelimit.ClientIP(g.r), path, size)
+	if !allowed {
+		return nil, errors.Err("rate limit exceeded, retry after %s", retryAfter)
+	}
+	r := g.r.Clone(g.r.Context())
+	r.Method = http.MethodGet
+	r.URL.Path = path
+	r.URL.RawQuery = params.Encode()
+	r.Form = params
+	r.PostForm = url.Values{}
+	r.Body = http.NoBody
+	return r, nil
+}
+
+var (
+	schemaOnce    sync.Once
+	graphQLSchema graphql.Schema
+)
+
+func newGraphQLSchema() (graphql.Schema, error) {
+	var claim *graphql.Object
+	claim = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Claim",
+		Description: "A claim as indexed by lighthouse.",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{
+				"channel": &graphql.Field{
+					Type:        claim,
+					Description: "The channel the claim was published in, looked up in batches across the query.",
+					Resolve:     resolveChannel,
+				},
+				"related": &graphql.Field{
+					Type:        graphql.NewList(graphql.NewNonNull(claim)),
+					Description: "Content related to the claim, as returned by search with related_to.",
+					Args: graphql.FieldConfigArgument{
+						"size": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: relatedSize},
+					},
+					Resolve: resolveRelated,
+				},
+			}
+			for _, s := range claimSources {
+				source := s.source
+				fields[s.field] = &graphql.Field{
+					Type: s.typ,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(map[string]interface{})[source], nil
+					},
+				}
+			}
+			return fields
+		}),
+	})
+
+	searchParams, searchRules := search.Params()
+	acRequest := &autoCompleteRequest{}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"search": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(claim)),
+				Description: "Search claims, it takes the parameters of /search.",
+				Args:        arguments(searchParams, searchRules),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return searchClaims(p, argumentValues(p.Args))
+				},
+			},
+			"autocomplete": &graphql.Field{
+				Type:        graphql.NewList(graphql.String),
+				Description: "Complete claim names, it takes the parameters of /autocomplete.",
+				Args:        arguments(acRequest, acRequest.rules()),
+				Resolve:     resolveAutoComplete,
+			},
+			"claim": &graphql.Field{
+				Type:        claim,
+				Description: "Look up a claim by claim id.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadClaim(p.Context, p.Args["id"].(string), false), nil
+				},
+			},
+			"channel": &graphql.Field{
+				Type:        claim,
+				Description: "Look up a channel by claim id.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadClaim(p.Context, p.Args["id"].(string), true), nil
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// arguments returns the graphql arguments of the api parameters, named and typed as they are documented.
+func arguments(params interface{}, rules []*v.FieldRules) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{}
+	for _, p := range openapi.Parameters(params, rules) {
+		var t graphql.Input
+		switch p["schema"].(map[string]interface{})["type"] {
+		case "integer":
+			t = graphql.Int
+		case "number":
+			t = graphql.Float
+		case "boolean":
+			t = graphql.Boolean
+		default:
+			t = graphql.String
+		}
+		if required, _ := p["required"].(bool); required {
+			t = graphql.NewNonNull(t)
+		}
+		description, _ := p["description"].(string)
+		args[p["name"].(string)] = &graphql.ArgumentConfig{Type: t, Description: description}
+	}
+	return args
+}
+
+func argumentValues(args map[string]interface{}) url.Values {
+	params := url.Values{}
+	for name, value := range args {
+		params.Set(name, fmt.Sprint(value))
+	}
+	return params
+}
+
+// searchClaims runs the search with the parameters, only fetching the fields of the claims the query selects.
+func searchClaims(p graphql.ResolveParams, params url.Values) (interface{}, error) {
+	if params.Get("debug") == "true" {
+		return nil, errors.Err("debug is not supported by graphql")
+	}
+	include := selectedSources(p.Info)
+	if params.Get("include") != "" {
+		include = append(include, params.Get("include"))
+	}
+	params.Set("include", strings.Join(include, ","))
+	r, err := fromContext(p.Context).request("/search", params)
+	if err != nil {
+		return nil, err
+	}
+	rsp := search.Search(r)
+	if rsp.Error != nil {
+		return nil, rsp.Error
+	}
+	hits, ok := rsp.Data.([]map[string]interface{})
+	if !ok {
+		return nil, errors.Err("unexpected search results")
+	}
+	return hits, nil
+}
+
+func resolveAutoComplete(p graphql.ResolveParams) (interface{}, error) {
+	params := argumentValues(p.Args)
+	if params.Get("debug") == "true" {
+		return nil, errors.Err("debug is not supported by graphql")
+	}
+	r, err := fromContext(p.Context).request("/autocomplete", params)
+	if err != nil {
+		return nil, err
+	}
+	rsp := AutoComplete(r)
+	if rsp.Error != nil {
+		return nil, rsp.Error
+	}
+	return rsp.Data, nil
+}
+
+func resolveRelated(p graphql.ResolveParams) (interface{}, error) {
+	claim := p.Source.(map[string]interface{})
+	claimID, _ := claim["claimId"].(string)
+	s, _ := claim["title"].(string)
+	if len(strings.TrimSpace(s)) < 3 {
+		s, _ = claim["name"].(string)
+	}
+	if claimID == "" || len(s) < 3 {
+		return []map[string]interface{}{}, nil
+	}
+	params := url.Values{"s": {s}, "related_to": {claimID}}
+	params.Set("size", fmt.Sprint(p.Args["size"]))
+	return searchClaims(p, params)
+}
+
+func resolveChannel(p graphql.ResolveParams) (interface{}, error) {
+	channelID, _ := p.Source.(map[string]interface{})["channel_claim_id"].(string)
+	if channelID == "" {
+		return nil, nil
+	}
+	return loadClaim(p.Context, channelID, true), nil
+}
+
+// loadClaim queues the claim in the loader of the query and returns a thunk that graphql resolves once every field
+// at the same depth has queued its claims.
+func loadClaim(ctx context.Context, claimID string, channel bool) func() (interface{}, error) {
+	load := fromContext(ctx).loader.Load(claimID)
+	return func() (interface{}, error) {
+		claim, err := load()
+		if err != nil || claim == nil {
+			return nil, err
+		}
+		if channel && claim["claim_type"] != "channel" {
+			return nil, nil
+		}
+		return claim, nil
+	}
+}
+
+// selectedSources returns the document fields needed to resolve the fields selected on the claims.
+func selectedSources(info graphql.ResolveInfo) []string {
+	var sources []string
+	var walk func(selections []ast.Selection)
+	walk = func(selections []ast.Selection) {
+		for _, selection := range selections {
+			switch s := selection.(type) {
+			case *ast.Field:
+				switch s.Name.Value {
+				case "channel":
+					sources = append(sources, "channel_claim_id")
+				case "related":
+					sources = append(sources, "title")
+				}
+				for _, c := range claimSources {
+					if c.field == s.Name.Value {
+						sources = append(sources, c.source)
+					}
+				}
+			case *ast.InlineFragment:
+				walk(s.SelectionSet.Selections)
+			case *ast.FragmentSpread:
+				if f, ok := info.Fragments[s.Name.Value].(*ast.FragmentDefinition); ok {
+					walk(f.SelectionSet.Selections)
+				}
+			}
+		}
+	}
+	for _, field := range info.FieldASTs {
+		if field.SelectionSet != nil {
+			walk(field.SelectionSet.Selections)
+		}
+	}
+	return sources
+}
+
+// GraphQL serves graphql queries over search, autocomplete and claim lookups. Queries can be sent as the query
+// parameter of a GET request or in the json body of a POST request. Claims looked up while resolving a query, such as
+// the channel of every search result, are fetched together in a single multi get per depth of the query.
+func GraphQL(w http.ResponseWriter, r *http.Request) {
+	for k, v := range api.ResponseHeaders {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		return
+	}
+	schemaOnce.Do(func() {
+		var err error
+		graphQLSchema, err = newGraphQLSchema()
+		if err != nil {
+			logrus.Panic(errors.Err(err))
+		}
+	})
+
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			err := json.Unmarshal([]byte(variables), &req.Variables)
+			if err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "invalid variables: "+err.Error())
+				return
+			}
+		}
+	case http.MethodPost:
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBody)).Decode(&req)
+		if err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	default:
+		writeGraphQLError(w, http.StatusMethodNotAllowed, "graphql queries must be sent with GET or POST")
+		return
+	}
+	if req.Query == "" {
+		writeGraphQLError(w, http.StatusBadRequest, "no query sent")
+		return
+	}
+	accesslog.SetParams(r, req)
+
+	ctx := context.WithValue(r.Context(), graphQLKey{}, &graphQLContext{r: r, loader: es.NewLoader(r.Context())})
+	result := graphql.Do(graphql.Params{
+		Schema:         graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+	status := http.StatusOK
+	if result.Data == nil && result.HasErrors() {
+		status = http.StatusBadRequest
+	}
+	writeGraphQL(w, status, result)
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, message string) {
+	writeGraphQL(w, status, map[string]interface{}{"errors": []map[string]string{{"message": message}}})
+}
+
+func writeGraphQL(w http.ResponseWriter, status int, result interface{}) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		logrus.Error(errors.Err(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/db"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/jobs/chainquery"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+)
+
+// MaxSyncLag is how far behind the claim sync can be before the instance is no longer ready to serve.
+var MaxSyncLag = time.Hour
+
+const checkTimeout = 2 * time.Second
+
+type check struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+	Latency string `json:"latency"`
+}
+
+type readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks map[string]check `json:"checks"`
+}
+
+// Healthz reports that the process is alive. It does not check any dependencies.
+func Healthz(r *http.Request) api.Response {
+	return api.Response{Data: "ok"}
+}
+
+// Readyz reports whether the instance can serve searches. It checks that elasticsearch is reachable and has the
+// claims index, that chainquery answers when the claims are synced from it and that the claim sync is not lagging
+// more than MaxSyncLag. It returns 503 with the result of each check when any of them fails.
+func Readyz(r *http.Request) api.Response {
+	result := readiness{Ready: true, Checks: make(map[string]check)}
+	run := func(name string, f func(ctx context.Context) (string, bool)) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+		start := time.Now()
+		message, ok := f(ctx)
+		result.Checks[name] = check{OK: ok, Message: message, Latency: time.Since(start).String()}
+		result.Ready = result.Ready && ok
+	}
+
+	run("elasticsearch", func(ctx context.Context) (string, bool) {
+		health, err := es.Client.ClusterHealth().Do(ctx)
+		if err != nil {
+			return err.Error(), false
+		}
+		return "cluster status " + health.Status, health.Status != "red"
+	})
+	run("index", func(ctx context.Context) (string, bool) {
+		exists, err := es.Client.IndexExists(index.Claims).Do(ctx)
+		if err != nil {
+			return err.Error(), false
+		}
+		if !exists {
+			return "index " + index.Claims + " does not exist", false
+		}
+		return "", true
+	})
+	if chainquery.SyncsFromChainquery() {
+		run("chainquery", func(ctx context.Context) (string, bool) {
+			if db.Chainquery == nil {
+				return "not connected", false
+			}
+			err := db.Chainquery.PingContext(ctx)
+			if err != nil {
+				return err.Error(), false
+			}
+			return "", true
+		})
+	}
+	run("sync", func(ctx context.Context) (string, bool) {
+		lastSync, err := chainquery.LastSyncTime()
+		if err != nil {
+			return err.Error(), false
+		}
+		if lastSync.IsZero() {
+			return "warming up, the initial claim sync has not completed", false
+		}
+		lag := time.Since(lastSync)
+		if lag > MaxSyncLag {
+			return "claim sync is " + lag.Round(time.Second).String() + " behind, more than " + MaxSyncLag.String(), false
+		}
+		return "claim sync is " + lag.Round(time.Second).String() + " behind", true
+	})
+
+	if !result.Ready {
+		return api.Response{Status: http.StatusServiceUnavailable, Data: result}
+	}
+	return api.Response{Data: result}
+}
@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/lbryio/lighthouse/app/actions/search"
+	"github.com/lbryio/lighthouse/app/admin"
+	"github.com/lbryio/lighthouse/app/alerts"
+	"github.com/lbryio/lighthouse/app/jobs/chainquery"
+	"github.com/lbryio/lighthouse/app/model"
+	"github.com/lbryio/lighthouse/app/openapi"
+	"github.com/lbryio/lighthouse/meta"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+)
+
+// searchHit documents a result of /search. Only name and claimId are always returned, resolve adds the channel,
+// channel_claim_id, title, thumbnail_url, release_time, fee, nsfw and duration and include adds the other properties
+// it lists.
+type searchHit struct {
+	model.Claim
+	Name    string `json:"name"`
+	ClaimID string `json:"claimId"`
+}
+
+var (
+	specOnce sync.Once
+	spec     openapi.Document
+)
+
+// Endpoints documents the routes of the api.
+func Endpoints() []openapi.Endpoint {
+	searchParams, searchRules := search.Params()
+	channelParams, channelRules := search.ChannelClaimsParams()
+	acRequest := &autoCompleteRequest{}
+	cRequest := &claimRequest{}
+	csRequest := &claimsRequest{}
+	dRequest := &deliveriesRequest{}
+	sRequest := &streamRequest{}
+	aRequest := &auditRequest{}
+	adminDescription := "Requires an admin token, sent as Authorization: Bearer <token>, or a client certificate on " +
+		"the admin port. Every action is recorded in the audit log."
+	searchDescription := "The camelCase contentType, mediaType and claimType parameters are named as the apps send them."
+	return []openapi.Endpoint{
+		{Path: "/", Summary: "Welcome message", Response: ""},
+		{Path: "/test", Summary: "Always answers ok", Response: ""},
+		{Path: "/search", Summary: "Search claims", Description: searchDescription + " Each claim has its name and " +
+			"claimId, the other properties are only returned when resolve is set or they are listed in include.",
+			Params: searchParams, Rules: searchRules, Response: []searchHit{}},
+		{Path: "/search.rss", Summary: "Search claims, newest first, as an RSS feed", Description: searchDescription,
+			Params: searchParams, Rules: searchRules, ContentType: "application/rss+xml"},
+		{Path: "/search.atom", Summary: "Search claims, newest first, as an Atom feed", Description: searchDescription,
+			Params: searchParams, Rules: searchRules, ContentType: "application/atom+xml"},
+		{Path: "/autocomplete", Summary: "Complete claim names", Params: acRequest, Rules: acRequest.rules(),
+			Response: []string{}},
+		{Path: "/claim/{claim_id}", Summary: "Claim by claim id or unique claim id prefix",
+			Description: "fields selects the returned fields as a comma separated list. Unknown and blocked claims " +
+				"are answered with 404.",
+			Params: cRequest, Rules: cRequest.rules(), Response: model.Claim{}},
+		{Path: "/claims", Summary: "Claims by comma separated claim ids or unique claim id prefixes",
+			Description: "Claims are returned in the order they were requested, unknown and blocked claims are " +
+				"listed as missing.",
+			Params: csRequest, Rules: csRequest.rules(), Response: claimsResult{}},
+		{Path: "/channel/{claim_id}/claims", Summary: "Streams of a channel along with the channel",
+			Description: "sort_by is one of release_time, view_cnt, effective_amount or trending, prefixed with ^ " +
+				"for ascending order. Pass next_cursor as the cursor parameter to get the next page.",
+			Params: channelParams, Rules: channelRules, Response: search.ChannelListing{}},
+		{Path: "/feed", Method: "post", Summary: "Latest streams of many channels, newest first",
+			Description: "per_channel limits the feed to the latest streams of each channel. Pass next_cursor as " +
+				"the cursor of the body to get the next page.",
+			Body: &search.ChannelFeedRequest{}, Response: search.ChannelFeedResult{}},
+		{Path: "/alerts", Summary: "Searches saved with the api key",
+			Description: "Saved searches require an api key, internal keys list every saved search.",
+			Params:      &noParams{}, Response: []alerts.SavedSearch{}},
+		{Path: "/alerts", Method: "post", Summary: "Save a search whose new matches are posted to a webhook",
+			Description: "Payloads are signed with the returned secret, see the X-Lighthouse-Signature header.",
+			Body:        &savedSearchRequest{}, Response: alerts.SavedSearch{}},
+		{Path: "/alerts/{id}", Summary: "Saved search", Response: alerts.SavedSearch{}},
+		{Path: "/alerts/{id}", Method: "delete", Summary: "Delete a saved search", Response: alerts.SavedSearch{}},
+		{Path: "/alerts/{id}/deliveries", Summary: "Latest webhook deliveries of a saved search, newest first",
+			Params: dRequest, Rules: dRequest.rules(), Response: []alerts.Delivery{}},
+		{Path: "/stream", Summary: "Newly indexed claims as server-sent events",
+			Description: "Each claim is sent as a claim event. Clients reconnecting with the Last-Event-ID header " +
+				"first receive the buffered events they missed, preceded by a reset event if some are no longer " +
+				"buffered. Streams of clients falling behind are closed with an overflow event.",
+			Params: sRequest, Rules: sRequest.rules(), ContentType: "text/event-stream"},
+		{Path: "/graphql", Method: "post", Summary: "Query search, autocomplete and claims with graphql",
+			Description: "Queries can also be sent as the query parameter of a GET request. A query makes at most " +
+				strconv.Itoa(maxGraphQLSearches) + " searches, each charged to the rate limit like a request to " +
+				"/search or /autocomplete.",
+			Body: &graphQLRequest{}, Response: map[string]interface{}{}},
+		{Path: "/admin/sync", Summary: "Claim sync state", Description: adminDescription,
+			Response: chainquery.SyncState{}},
+		{Path: "/admin/sync", Method: "post", Summary: "Run the claim sync, for a channel or a range of claims if set",
+			Description: adminDescription, Body: &SyncRequest{}, Response: ""},
+		{Path: "/admin/sync/reset", Method: "post", Summary: "Reset the claim sync state for a full sync",
+			Description: adminDescription, Body: &ResetRequest{}, Response: ""},
+		{Path: "/admin/claims/delete", Method: "post", Summary: "Remove claims from the index",
+			Description: adminDescription, Body: &ClaimIDsRequest{}, Response: ClaimsResult{}},
+		{Path: "/admin/claims/reindex", Method: "post", Summary: "Index claims again from Chainquery",
+			Description: adminDescription, Body: &ClaimIDsRequest{}, Response: ClaimsResult{}},
+		{Path: "/admin/blocked", Method: "post", Summary: "Process the blocked and filtered lists",
+			Description: adminDescription, Body: &BlockedRequest{}, Response: ""},
+		{Path: "/admin/loglevel", Summary: "Log level", Description: adminDescription, Response: LogLevelRequest{}},
+		{Path: "/admin/loglevel", Method: "post", Summary: "Change the log level", Description: adminDescription,
+			Body: &LogLevelRequest{}, Response: LogLevelRequest{}},
+		{Path: "/admin/audit", Summary: "Latest entries of the audit log, newest first",
+			Description: adminDescription, Params: aRequest, Rules: aRequest.rules(), Response: []admin.Entry{}},
+		{Path: "/status", Summary: "Version, circuit breaker and elasticsearch status", Response: status{}},
+		{Path: "/status/jobs", Summary: "Background jobs, claim sync, bulk processor and cache status, for internal api keys and admins",
+			Response: jobsStatus{}},
+		{Path: "/healthz", Summary: "Liveness check", Response: ""},
+		{Path: "/readyz", Summary: "Readiness check of the dependencies", Response: readiness{}},
+		{Path: "/openapi.json", Summary: "This document", Response: map[string]interface{}{}},
+	}
+}
+
+// OpenAPI returns the OpenAPI 3 document of the api.
+func OpenAPI(r *http.Request) api.Response {
+	specOnce.Do(func() {
+		spec = openapi.Generate("Lighthouse", meta.GetSemVersion(), Endpoints())
+	})
+	return api.Response{Data: spec}
+}
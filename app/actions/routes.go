@@ -15,6 +15,11 @@ type Routes struct {
 }
 
 func (r *Routes) set(key string, h api.Handler) {
+	r.handle(key, h)
+}
+
+// handle sets a handler that writes its own response instead of a json api response.
+func (r *Routes) handle(key string, h http.Handler) {
 	if r.m == nil {
 		r.m = orderedmap.New()
 	}
@@ -39,8 +44,23 @@ func GetRoutes() *Routes {
 	routes.set("/test", Test)
 
 	routes.set("/search", search.Search)
+	routes.handle("/search.rss", http.HandlerFunc(search.RSS))
+	routes.handle("/search.atom", http.HandlerFunc(search.Atom))
 	routes.set("/autocomplete", AutoComplete)
+	routes.set("/claim/", Claim)
+	routes.set("/claims", Claims)
+	routes.set("/channel/", search.ChannelClaims)
+	routes.set("/feed", search.ChannelFeed)
+	routes.set("/alerts", SavedSearches)
+	routes.set("/alerts/", SavedSearch)
+	routes.handle("/stream", http.HandlerFunc(ClaimStream))
+	routes.set("/admin/", Admin)
+	routes.handle("/graphql", http.HandlerFunc(GraphQL))
 	routes.set("/status", Status)
+	routes.set("/status/jobs", JobsStatus)
+	routes.set("/healthz", Healthz)
+	routes.set("/readyz", Readyz)
+	routes.set("/openapi.json", OpenAPI)
 
 	return &routes
 }
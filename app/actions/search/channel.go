@@ -0,0 +1,307 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/cache"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/httpcache"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/jobs/blocked"
+	"github.com/lbryio/lighthouse/app/timeout"
+	"github.com/lbryio/lighthouse/app/validator"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	v "github.com/lbryio/ozzo-validation"
+
+	"gopkg.in/olivere/elastic.v6"
+)
+
+const defaultChannelClaimsSize = 20
+
+var channelClaimsPath = regexp.MustCompile(`^/channel/([0-9a-fA-F]{40})/claims/?$`)
+
+// channelSorts maps the sorts of channel listings to the fields they sort by, trending sorts by score.
+var channelSorts = map[string]string{
+	"release_time":     "release_time",
+	"view_cnt":         "view_cnt",
+	"effective_amount": "effective_amount",
+	"trending":         "_score",
+}
+
+type channelClaimsRequest struct {
+	Size        *int
+	Cursor      *string
+	SortBy      *string
+	MediaType   *string
+	Tags        *string
+	MinDuration *int
+	MaxDuration *int
+	NSFW        *bool
+	Include     *string
+	Resolve     bool
+}
+
+func (r *channelClaimsRequest) rules() []*v.FieldRules {
+	var sorts []interface{}
+	for sort := range channelSorts {
+		sorts = append(sorts, sort, "^"+sort)
+	}
+	return []*v.FieldRules{
+		v.Field(&r.Size, v.Min(1), v.Max(10000)),
+		v.Field(&r.SortBy, v.In(sorts...)),
+		v.Field(&r.MediaType, validator.MediaTypeValidator),
+		v.Field(&r.Tags, v.Length(1, 0)),
+		v.Field(&r.MinDuration, v.Min(0)),
+		v.Field(&r.MaxDuration, v.Min(0)),
+	}
+}
+
+// ChannelClaimsParams returns the parameters of ChannelClaims and their validation rules, used to document the api.
+func ChannelClaimsParams() (interface{}, []*v.FieldRules) {
+	r := &channelClaimsRequest{}
+	return r, r.rules()
+}
+
+// ChannelHeader holds the fields of the channel document returned with its claims.
+type ChannelHeader struct {
+	ClaimID      string `json:"claimId"`
+	Name         string `json:"name"`
+	Title        string `json:"title,omitempty"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	SubCnt       uint64 `json:"sub_cnt"`
+	ClaimCnt     uint64 `json:"claim_cnt"`
+}
+
+// ChannelListing is the response of ChannelClaims.
+type ChannelListing struct {
+	Channel ChannelHeader            `json:"channel"`
+	Claims  []map[string]interface{} `json:"claims"`
+	// NextCursor is passed as the cursor parameter to get the next page, it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// channelClaimsResult is the cached result of a channel listing, found is false if the channel is not indexed.
+type channelClaimsResult struct {
+	found  bool
+	claims ChannelListing
+	// took is the time elasticsearch spent on the listing the claims were fetched with.
+	took     int64
+	partial  bool
+	timedOut bool
+}
+
+// ChannelClaims API returns the streams of the channel with the claim id in the path, /channel/{claim_id}/claims,
+// along with the channel itself. Unlike a search restricted to the channel no text is scored, the streams are sorted
+// by release_time unless another sort is requested and paged through with the returned cursor.
+func ChannelClaims(r *http.Request) api.Response {
+	req := channelClaimsRequest{}
+	err := api.FormValues(r, &req, req.rules())
+	if err != nil {
+		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+	}
+	match := channelClaimsPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		return api.Response{Error: errors.Err("not found, channels are listed at /channel/{claim_id}/claims"),
+			Status: http.StatusNotFound}
+	}
+	channelID := strings.ToLower(match[1])
+	err = auth.FromRequest(r).CheckLimits(req.Size, nil, false)
+	if err != nil {
+		return api.Response{Error: errors.Err(err)}
+	}
+	if blocked.IsBlocked(channelID) {
+		return api.Response{Error: errors.Err("channel %s not found", channelID), Status: http.StatusNotFound}
+	}
+	sortBy := "release_time"
+	if req.SortBy != nil {
+		sortBy = *req.SortBy
+	}
+	after, err := decodeCursor(req.Cursor, sortBy)
+	if err != nil {
+		return api.Response{Error: errors.Err(err)}
+	}
+	size := defaultChannelClaimsSize
+	if req.Size != nil {
+		size = *req.Size
+	}
+
+	filters := searchRequest{MediaType: req.MediaType, NSFW: req.NSFW}
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("channel_claim_id.keyword", channelID), streamOnlyMatch, filters.bidStateFilter())
+	if mediaType := filters.mediaTypeFilter(); len(mediaType) > 0 {
+		query.Filter(elastic.NewBoolQuery().Should(mediaType...))
+	} else if req.MediaType != nil {
+		query.Filter(elastic.NewMatchNoneQuery())
+	}
+	if nsfw := filters.nsfwFilter(); nsfw != nil {
+		query.Filter(nsfw)
+	}
+	if req.Tags != nil {
+		var tags []interface{}
+		for _, tag := range strings.Split(*req.Tags, ",") {
+			tags = append(tags, strings.TrimSpace(tag))
+		}
+		query.Filter(elastic.NewTermsQuery("tags.keyword", tags...))
+	}
+	if req.MinDuration != nil || req.MaxDuration != nil {
+		duration := elastic.NewRangeQuery("duration")
+		if req.MinDuration != nil {
+			duration.Gte(*req.MinDuration)
+		}
+		if req.MaxDuration != nil {
+			duration.Lte(*req.MaxDuration)
+		}
+		query.Filter(duration)
+	}
+	var listing elastic.Query = query
+	field := channelSorts[strings.TrimPrefix(sortBy, "^")]
+	// The trending origin of the first page is kept by its cursor, so that the next pages are scored alike.
+	origin := time.Now().UTC().Truncate(time.Hour)
+	if after != nil && after.Origin != 0 {
+		origin = time.Unix(after.Origin, 0).UTC()
+	}
+	if field == "_score" {
+		listing = trendingQuery(query, origin)
+	}
+	sort := elastic.NewFieldSort(field).Order(strings.HasPrefix(sortBy, "^"))
+	if field != "_score" {
+		sort = sort.Missing("_last")
+	}
+	source := searchRequest{Include: req.Include, Resolve: req.Resolve}.sourceContext()
+	search := elastic.NewSearchSource().
+		Query(listing).
+		FetchSourceContext(source).
+		Size(size).
+		SortBy(sort, elastic.NewFieldSort("claimId.keyword").Asc())
+	if after != nil {
+		search = search.SearchAfter(after.After...)
+	}
+	if shards, ok := timeout.Shards(r.Context()); ok {
+		search = search.Timeout(shards)
+	}
+	header := elastic.NewSearchSource().
+		Query(elastic.NewBoolQuery().Filter(elastic.NewIdsQuery(index.ClaimType).Ids(channelID), ChannelOnlyMatch)).
+		FetchSourceContext(elastic.NewFetchSourceContext(true).Include("claimId", "name", "title", "thumbnail_url", "sub_cnt", "claim_cnt")).
+		Size(1)
+
+	key := cache.Key(r)
+	value, cacheStatus, err := searchCache.Fetch(r.Context(), key, func(ctx context.Context) (interface{}, error) {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+		esStart := time.Now()
+		res, err := es.Client.MultiSearch().
+			Add(elastic.NewSearchRequest().Index(index.Claims).SearchSource(header)).
+			Add(elastic.NewSearchRequest().Index(index.Claims).SearchSource(search)).
+			Do(ctx)
+		es.Breaker.Record(time.Since(esStart), err)
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		if len(res.Responses) != 2 {
+			return nil, errors.Err("expected 2 responses from elasticsearch, got %d", len(res.Responses))
+		}
+		for _, r := range res.Responses {
+			if r.Error != nil {
+				return nil, errors.Err(r.Error.Reason)
+			}
+		}
+		channel, claims := res.Responses[0], res.Responses[1]
+		metrics.ES(esStart, "channel_listing", claims.TookInMillis, claims.Hits.TotalHits)
+		if len(channel.Hits.Hits) == 0 {
+			return channelClaimsResult{took: claims.TookInMillis}, nil
+		}
+		result := channelClaimsResult{found: true, took: claims.TookInMillis}
+		headers := decodeHits(channel.Hits.Hits)
+		if len(headers) > 0 {
+			result.claims.Channel = channelHeader(headers[0])
+		}
+		result.claims.Channel.ClaimID = channelID
+		result.claims.Claims = decodeHits(claims.Hits.Hits)
+		if hits := claims.Hits.Hits; len(hits) == size {
+			next := cursor{Sort: sortBy, After: hits[len(hits)-1].Sort}
+			if field == "_score" {
+				next.Origin = origin.Unix()
+			}
+			result.claims.NextCursor = next.encode()
+		}
+		result.partial, result.timedOut = es.Partial(claims)
+		if result.partial {
+			metrics.Incomplete("channel_listing", result.timedOut)
+			return cache.NoStore(result), nil
+		}
+		return result, nil
+	})
+	served := string(cacheStatus)
+	if errors.Is(err, breaker.ErrOpen) {
+		stale, ok := searchCache.Peek(key)
+		value, err = breaker.Stale(r, "elasticsearch", "channel_listing", stale, ok)
+		served = "degraded"
+	}
+	if err != nil {
+		return api.Response{Error: errors.Err(timeout.Error(err))}
+	}
+	result := value.(channelClaimsResult)
+	accesslog.SetTook(r, result.took)
+	if !result.found {
+		return api.Response{Error: errors.Err("channel %s not found", channelID), Status: http.StatusNotFound}
+	}
+	if result.partial {
+		timeout.SetPartial(r, result.timedOut)
+	}
+	accesslog.SetCache(r, served)
+	accesslog.SetResults(r, len(result.claims.Claims))
+	metrics.Results("channel_listing", 0, len(result.claims.Claims))
+	httpcache.AddClaims(r, channelID)
+	for _, claim := range result.claims.Claims {
+		if claimID, ok := claim["claimId"].(string); ok {
+			httpcache.AddClaims(r, claimID)
+		}
+	}
+	return api.Response{Data: result.claims}
+}
+
+// trendingQuery scores the claims matching query by their views, decayed by how long before origin they were
+// released. The origin is truncated to the hour so that listings share cache entries, and carried over by cursors so
+// that the scores hold while paging.
+func trendingQuery(query elastic.Query, origin time.Time) elastic.Query {
+	return elastic.NewFunctionScoreQuery().
+		Query(query).
+		BoostMode("replace").
+		ScoreMode("multiply").
+		AddScoreFunc(elastic.NewFieldValueFactorFunction().Field("view_cnt").Missing(0).Modifier("log2p")).
+		AddScoreFunc(elastic.NewGaussDecayFunction().
+			FieldName("release_time").
+			Origin(origin).
+			Scale("7d").
+			Decay(0.5))
+}
+
+func channelHeader(source map[string]interface{}) ChannelHeader {
+	str := func(field string) string {
+		s, _ := source[field].(string)
+		return s
+	}
+	num := func(field string) uint64 {
+		n, _ := source[field].(float64)
+		return uint64(n)
+	}
+	return ChannelHeader{
+		Name:         str("name"),
+		Title:        str("title"),
+		ThumbnailURL: str("thumbnail_url"),
+		SubCnt:       num("sub_cnt"),
+		ClaimCnt:     num("claim_cnt"),
+	}
+}
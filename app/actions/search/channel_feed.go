@@ -0,0 +1,304 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/cache"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/jobs/blocked"
+	"github.com/lbryio/lighthouse/app/timeout"
+	"github.com/lbryio/lighthouse/app/validator"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	v "github.com/lbryio/ozzo-validation"
+
+	"gopkg.in/olivere/elastic.v6"
+)
+
+// MaxFeedChannels is the largest number of channels a feed can be built from.
+var MaxFeedChannels = 500
+
+const (
+	defaultFeedSize = 20
+	claimIDLength   = 40
+	maxFeedBody     = 1 << 16
+	// maxPerChannel is bounded by the default index.max_inner_result_window of elasticsearch.
+	maxPerChannel = 50
+)
+
+// ChannelFeedRequest is the json body of ChannelFeed.
+type ChannelFeedRequest struct {
+	ChannelIDs []string `json:"channel_ids"`
+	Size       *int     `json:"size,omitempty"`
+	Cursor     *string  `json:"cursor,omitempty"`
+	// PerChannel limits the feed to the latest streams of each channel.
+	PerChannel *int    `json:"per_channel,omitempty"`
+	NSFW       *bool   `json:"nsfw,omitempty"`
+	MediaType  *string `json:"media_type,omitempty"`
+	Include    *string `json:"include,omitempty"`
+	Resolve    bool    `json:"resolve,omitempty"`
+}
+
+func (r *ChannelFeedRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.ChannelIDs, v.Required, v.Length(1, MaxFeedChannels)),
+		v.Field(&r.Size, v.Min(1), v.Max(10000)),
+		v.Field(&r.PerChannel, v.Min(1), v.Max(maxPerChannel)),
+		v.Field(&r.MediaType, validator.MediaTypeValidator),
+	}
+}
+
+// ChannelFeedResult is the response of ChannelFeed.
+type ChannelFeedResult struct {
+	Claims []map[string]interface{} `json:"claims"`
+	// NextCursor is passed as the cursor of the request body to get the next page, it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// channelFeedResult is the cached result of a feed.
+type channelFeedResult struct {
+	feed ChannelFeedResult
+	// took is the time elasticsearch spent on the search the feed was fetched with.
+	took     int64
+	partial  bool
+	timedOut bool
+}
+
+// ChannelFeed API returns the latest streams of the channels in the json body of a POST request, merged and sorted by
+// release_time, newest first. The feed is paged through with the returned cursor, and per_channel collapses it to the
+// latest streams of each channel so a single prolific channel cannot fill it.
+func ChannelFeed(r *http.Request) api.Response {
+	if r.Method != http.MethodPost {
+		return api.Response{Error: errors.Err("feeds must be requested with POST"), Status: http.StatusMethodNotAllowed}
+	}
+	req := ChannelFeedRequest{}
+	d := json.NewDecoder(io.LimitReader(r.Body, maxFeedBody))
+	d.DisallowUnknownFields()
+	err := d.Decode(&req)
+	if err != nil {
+		return api.Response{Error: errors.Err("invalid request body: %s", err), Status: http.StatusBadRequest}
+	}
+	accesslog.SetParams(r, req)
+	err = v.ValidateStruct(&req, req.rules()...)
+	if err != nil {
+		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+	}
+	err = auth.FromRequest(r).CheckLimits(req.Size, nil, false)
+	if err != nil {
+		return api.Response{Error: errors.Err(err)}
+	}
+	var channelIDs []string
+	seen := make(map[string]bool)
+	for _, id := range req.ChannelIDs {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if len(id) != claimIDLength || validator.ClaimIDsValidator.Validate(id) != nil {
+			return api.Response{Error: errors.Err("channel_ids: invalid claim id %q, must be 40 hexadecimal characters", id),
+				Status: http.StatusBadRequest}
+		}
+		if !seen[id] && !blocked.IsBlocked(id) {
+			seen[id] = true
+			channelIDs = append(channelIDs, id)
+		}
+	}
+	sort.Strings(channelIDs)
+	after, err := decodeCursor(req.Cursor, "release_time")
+	if err != nil {
+		return api.Response{Error: errors.Err(err)}
+	}
+	if len(channelIDs) == 0 {
+		return api.Response{Data: ChannelFeedResult{Claims: []map[string]interface{}{}}}
+	}
+	size := defaultFeedSize
+	if req.Size != nil {
+		size = *req.Size
+	}
+
+	channels := make([]interface{}, len(channelIDs))
+	for i, id := range channelIDs {
+		channels[i] = id
+	}
+	filters := searchRequest{MediaType: req.MediaType, NSFW: req.NSFW}
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermsQuery("channel_claim_id.keyword", channels...), streamOnlyMatch, filters.bidStateFilter())
+	if mediaType := filters.mediaTypeFilter(); len(mediaType) > 0 {
+		query.Filter(elastic.NewBoolQuery().Should(mediaType...))
+	} else if req.MediaType != nil {
+		query.Filter(elastic.NewMatchNoneQuery())
+	}
+	if nsfw := filters.nsfwFilter(); nsfw != nil {
+		query.Filter(nsfw)
+	}
+	source := searchRequest{Include: req.Include, Resolve: req.Resolve}.sourceContext().Include("release_time")
+	sorts := []elastic.Sorter{
+		elastic.NewFieldSort("release_time").Desc().Missing("_last"),
+		elastic.NewFieldSort("claimId.keyword").Asc(),
+	}
+
+	search := elastic.NewSearchSource().Query(query).FetchSourceContext(source)
+	if req.PerChannel == nil {
+		search = search.Size(size).SortBy(sorts...)
+		if after != nil {
+			search = search.SearchAfter(after.After...)
+		}
+	} else {
+		// Elasticsearch cannot collapse and page with search_after at once, so the latest streams of each channel
+		// are fetched and merged here.
+		latest := elastic.NewTopHitsAggregation().Size(*req.PerChannel).SortBy(sorts...).FetchSourceContext(source)
+		search = search.Size(0).Aggregation("channels", elastic.NewTermsAggregation().
+			Field("channel_claim_id.keyword").
+			Size(len(channelIDs)).
+			SubAggregation("latest", latest))
+	}
+	if shards, ok := timeout.Shards(r.Context()); ok {
+		search = search.Timeout(shards)
+	}
+
+	values := url.Values{"channel_ids": {strings.Join(channelIDs, ",")}, "size": {strconv.Itoa(size)}}
+	if req.Cursor != nil {
+		values.Set("cursor", *req.Cursor)
+	}
+	if req.PerChannel != nil {
+		values.Set("per_channel", strconv.Itoa(*req.PerChannel))
+	}
+	if req.NSFW != nil {
+		values.Set("nsfw", strconv.FormatBool(*req.NSFW))
+	}
+	if req.MediaType != nil {
+		values.Set("media_type", *req.MediaType)
+	}
+	if req.Include != nil {
+		values.Set("include", *req.Include)
+	}
+	values.Set("resolve", strconv.FormatBool(req.Resolve))
+	key := r.URL.Path + "?" + values.Encode()
+	value, cacheStatus, err := searchCache.Fetch(r.Context(), key, func(ctx context.Context) (interface{}, error) {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+		esStart := time.Now()
+		results, err := es.Client.Search(index.Claims).SearchSource(search).Do(ctx)
+		es.Breaker.Record(time.Since(esStart), err)
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		metrics.ES(esStart, "channel_feed", results.TookInMillis, results.Hits.TotalHits)
+		hits := results.Hits.Hits
+		if req.PerChannel != nil {
+			hits, err = latestHits(results, after, size)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result := channelFeedResult{feed: ChannelFeedResult{Claims: decodeHits(hits)}, took: results.TookInMillis}
+		if len(hits) == size {
+			result.feed.NextCursor = cursor{Sort: "release_time", After: hits[len(hits)-1].Sort}.encode()
+		}
+		result.partial, result.timedOut = es.Partial(results)
+		if result.partial {
+			metrics.Incomplete("channel_feed", result.timedOut)
+			return cache.NoStore(result), nil
+		}
+		return result, nil
+	})
+	served := string(cacheStatus)
+	if errors.Is(err, breaker.ErrOpen) {
+		stale, ok := searchCache.Peek(key)
+		value, err = breaker.Stale(r, "elasticsearch", "channel_feed", stale, ok)
+		served = "degraded"
+	}
+	if err != nil {
+		return api.Response{Error: errors.Err(timeout.Error(err))}
+	}
+	result := value.(channelFeedResult)
+	if result.partial {
+		timeout.SetPartial(r, result.timedOut)
+	}
+	accesslog.SetTook(r, result.took)
+	accesslog.SetCache(r, served)
+	accesslog.SetResults(r, len(result.feed.Claims))
+	metrics.Results("channel_feed", 0, len(result.feed.Claims))
+	return api.Response{Data: result.feed}
+}
+
+// latestHits merges the latest streams of each channel from the channels aggregation into a page of size hits
+// following the cursor, sorted as the search would have sorted them.
+func latestHits(results *elastic.SearchResult, after *cursor, size int) ([]*elastic.SearchHit, error) {
+	channels, ok := results.Aggregations.Terms("channels")
+	if !ok {
+		return nil, errors.Err("channels aggregation missing from the elasticsearch response")
+	}
+	var hits []*elastic.SearchHit
+	for _, bucket := range channels.Buckets {
+		latest, ok := bucket.TopHits("latest")
+		if !ok || latest.Hits == nil {
+			continue
+		}
+		hits = append(hits, latest.Hits.Hits...)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return compareSort(hits[i].Sort, hits[j].Sort) < 0
+	})
+	if after != nil {
+		i := sort.Search(len(hits), func(i int) bool {
+			return compareSort(hits[i].Sort, after.After) > 0
+		})
+		hits = hits[i:]
+	}
+	if len(hits) > size {
+		hits = hits[:size]
+	}
+	return hits, nil
+}
+
+// compareSort compares the sort values of two hits sorted by release_time descending then claim id ascending, hits
+// without a release_time sort last.
+func compareSort(a, b []interface{}) int {
+	if len(a) != 2 || len(b) != 2 {
+		return 0
+	}
+	ta, oka := sortNumber(a[0])
+	tb, okb := sortNumber(b[0])
+	switch {
+	case oka && !okb:
+		return -1
+	case !oka && okb:
+		return 1
+	case ta > tb:
+		return -1
+	case ta < tb:
+		return 1
+	}
+	return strings.Compare(toString(a[1]), toString(b[1]))
+}
+
+func sortNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		// Missing values sort as the smallest long.
+		return n, n > -9.2e18
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil && f > -9.2e18
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// cursor is the position of the last claim of a page, passed back by the caller to get the next page. It holds the
+// sort values of the claim for search_after along with the sort they belong to.
+type cursor struct {
+	Sort  string        `json:"s"`
+	After []interface{} `json:"a"`
+	// Origin is the unix time claims were scored from by a trending sort, so that every page is scored alike.
+	Origin int64 `json:"o,omitempty"`
+}
+
+func (c cursor) encode() string {
+	after := make([]interface{}, len(c.After))
+	for i, value := range c.After {
+		// Missing values sort as the smallest or largest long, which do not survive the round trip through a float64.
+		if f, ok := value.(float64); ok && f <= math.MinInt64 {
+			value = json.Number(strconv.FormatInt(math.MinInt64, 10))
+		} else if ok && f >= math.MaxInt64 {
+			value = json.Number(strconv.FormatInt(math.MaxInt64, 10))
+		}
+		after[i] = value
+	}
+	c.After = after
+	b, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor decodes the cursor parameter, checking that it was returned for the same sort.
+func decodeCursor(param *string, sort string) (*cursor, error) {
+	if param == nil {
+		return nil, nil
+	}
+	invalid := api.StatusError{Status: http.StatusBadRequest, Err: errors.Err("cursor: invalid cursor")}
+	b, err := base64.RawURLEncoding.DecodeString(*param)
+	if err != nil {
+		return nil, invalid
+	}
+	c := &cursor{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if d.Decode(c) != nil || len(c.After) == 0 {
+		return nil, invalid
+	}
+	if c.Sort != sort {
+		return nil, api.StatusError{Status: http.StatusBadRequest,
+			Err: errors.Err("cursor: the cursor was returned for another sort")}
+	}
+	return c, nil
+}
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/timeout"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// MaxExport is the largest number of claims a single export streams.
+var MaxExport = 100000
+
+const exportBatch = 500
+
+// Export sends every claim matching the search of the request to send, scrolling through the matches in batches
+// instead of returning a single page. It takes the parameters of Search except from and the debug parameters, size
+// caps the number of claims sent and defaults to MaxExport. Exports require an API key.
+func Export(r *http.Request, send func(claim map[string]interface{}) error) error {
+	searchRequest := searchRequest{}
+	err := api.FormValues(r, &searchRequest, searchRequest.rules())
+	if err != nil {
+		return api.StatusError{Status: http.StatusBadRequest, Err: errors.Err(err)}
+	}
+	if auth.FromRequest(r).Name == "" {
+		return api.StatusError{Status: http.StatusForbidden, Err: errors.Err("exports require an api key")}
+	}
+	if searchRequest.From != nil || searchRequest.Debug || searchRequest.Source || searchRequest.Score {
+		return api.StatusError{Status: http.StatusBadRequest,
+			Err: errors.Err("from and the debug parameters are not supported by exports")}
+	}
+	limit := MaxExport
+	if searchRequest.Size != nil && *searchRequest.Size < limit {
+		limit = *searchRequest.Size
+	}
+	searchRequest.searchType = "export"
+	searchRequest.S = truncate(searchRequest.S)
+	searchRequest.S = checkForSpecialHandling(searchRequest.S)
+	searchRequest.terms = len(strings.Split(searchRequest.S, " "))
+	searchRequest.recordFilterUsage()
+
+	batch := exportBatch
+	if limit < batch {
+		batch = limit
+	}
+	scroll := es.Client.
+		Scroll("claims").
+		Query(searchRequest.newQuery()).
+		FetchSourceContext(searchRequest.sourceContext()).
+		Size(batch).
+		Header(accesslog.OpaqueIDHeader, accesslog.RequestID(r))
+	if searchRequest.SortBy != nil {
+		sortBy := strings.TrimPrefix(*searchRequest.SortBy, "^")
+		scroll = scroll.Sort(sortBy, strings.Contains(*searchRequest.SortBy, "^"))
+	}
+	defer func() {
+		_ = scroll.Clear(context.Background())
+	}()
+
+	sent := 0
+	for sent < limit {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return breaker.Unavailable("elasticsearch")
+		}
+		esStart := time.Now()
+		results, err := scroll.Do(r.Context())
+		if err == io.EOF {
+			es.Breaker.Record(time.Since(esStart), nil)
+			break
+		}
+		es.Breaker.Record(time.Since(esStart), err)
+		if err != nil {
+			return errors.Err(timeout.Error(err))
+		}
+		metrics.ES(esStart, searchRequest.searchType, results.TookInMillis, results.Hits.TotalHits)
+		if partial, timedOut := es.Partial(results); partial {
+			metrics.Incomplete(searchRequest.searchType, timedOut)
+			timeout.SetPartial(r, timedOut)
+		}
+		for _, claim := range decodeHits(results.Hits.Hits) {
+			err := send(claim)
+			if err != nil {
+				return err
+			}
+			sent++
+			if sent >= limit {
+				break
+			}
+		}
+	}
+	accesslog.SetResults(r, sent)
+	metrics.Results(searchRequest.searchType, searchRequest.terms, sent)
+	return nil
+}
@@ -0,0 +1,288 @@
+package search
+
+import (
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebURL is the base url of the web app that feed entries link to.
+var WebURL = "https://odysee.com"
+
+const (
+	feedSize        = 50
+	feedSnippetSize = 300
+)
+
+// RSS serves the results of a search, newest first, as an RSS 2.0 feed. It takes the same parameters as Search.
+func RSS(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, "application/rss+xml; charset=utf-8", renderRSS)
+}
+
+// Atom serves the results of a search, newest first, as an Atom feed. It takes the same parameters as Search.
+func Atom(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, "application/atom+xml; charset=utf-8", renderAtom)
+}
+
+// feedEntry holds the resolved fields of a search result used by the feeds.
+type feedEntry struct {
+	Title       string
+	Description string
+	Thumbnail   string
+	Channel     string
+	Released    time.Time
+	LbryURL     string
+	WebURL      string
+}
+
+type feed struct {
+	Title   string
+	SelfURL string
+	Updated time.Time
+	Entries []feedEntry
+}
+
+func serveFeed(w http.ResponseWriter, r *http.Request, contentType string, render func(feed) interface{}) {
+	err := r.ParseForm()
+	if err != nil {
+		api.Handler(func(r *http.Request) api.Response {
+			return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
+		}).ServeHTTP(w, r)
+		return
+	}
+	r.Form.Set("sort_by", "release_time")
+	r.Form.Set("resolve", "true")
+	include := []string{"description"}
+	if r.Form.Get("include") != "" {
+		include = append(include, r.Form.Get("include"))
+	}
+	r.Form.Set("include", strings.Join(include, ","))
+	if r.Form.Get("size") == "" {
+		r.Form.Set("size", strconv.Itoa(feedSize))
+	}
+
+	rsp := Search(r)
+	results, ok := rsp.Data.([]map[string]interface{})
+	if rsp.Error == nil && !ok {
+		rsp = api.Response{Error: errors.Err("debug parameters are not supported by feeds"), Status: http.StatusBadRequest}
+	}
+	if rsp.Error != nil {
+		api.Handler(func(r *http.Request) api.Response { return rsp }).ServeHTTP(w, r)
+		return
+	}
+
+	f := feed{Title: "LBRY search: " + r.Form.Get("s"), SelfURL: selfURL(r), Updated: time.Now().UTC()}
+	for _, result := range results {
+		e := newFeedEntry(result)
+		if len(f.Entries) == 0 && !e.Released.IsZero() {
+			f.Updated = e.Released
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	body, err := xml.MarshalIndent(render(f), "", "  ")
+	if err != nil {
+		logrus.Error(errors.Err(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for k, v := range api.ResponseHeaders {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(body)
+}
+
+func newFeedEntry(result map[string]interface{}) feedEntry {
+	str := func(field string) string {
+		s, _ := result[field].(string)
+		return s
+	}
+	name, claimID := str("name"), str("claimId")
+	e := feedEntry{
+		Title:       str("title"),
+		Description: snippet(str("description")),
+		Thumbnail:   str("thumbnail_url"),
+		Channel:     str("channel"),
+		LbryURL:     "lbry://" + name + "#" + claimID,
+		WebURL:      WebURL + "/" + name + ":" + claimID,
+	}
+	if e.Title == "" {
+		e.Title = name
+	}
+	if e.Channel != "" && str("channel_claim_id") != "" {
+		e.LbryURL = "lbry://" + e.Channel + "#" + str("channel_claim_id") + "/" + name + "#" + claimID
+		e.WebURL = WebURL + "/" + e.Channel + ":" + str("channel_claim_id") + "/" + name + ":" + claimID
+	}
+	if released, err := time.Parse(time.RFC3339, str("release_time")); err == nil {
+		e.Released = released.UTC()
+	}
+	return e
+}
+
+// snippet shortens the description to feedSnippetSize characters at a word boundary.
+func snippet(description string) string {
+	runes := []rune(strings.TrimSpace(description))
+	if len(runes) <= feedSnippetSize {
+		return string(runes)
+	}
+	s := string(runes[:feedSnippetSize])
+	if i := strings.LastIndexAny(s, " \n\t"); i > 0 {
+		s = s[:i]
+	}
+	return s + "…"
+}
+
+func selfURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func thumbnailType(url string) string {
+	if t := mime.TypeByExtension(path.Ext(strings.SplitN(url, "?", 2)[0])); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	return "image/jpeg"
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description,omitempty"`
+	Creator     string        `xml:"dc:creator,omitempty"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	GUID        rssGUID       `xml:"guid"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func renderRSS(f feed) interface{} {
+	channel := rssChannel{
+		Title:         f.Title,
+		Link:          WebURL,
+		Description:   f.Title,
+		Self:          atomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		LastBuildDate: f.Updated.Format(time.RFC1123Z),
+	}
+	for _, e := range f.Entries {
+		item := rssItem{
+			Title:       e.Title,
+			Link:        e.WebURL,
+			Description: e.Description,
+			Creator:     e.Channel,
+			GUID:        rssGUID{Value: e.LbryURL},
+		}
+		if !e.Released.IsZero() {
+			item.PubDate = e.Released.Format(time.RFC1123Z)
+		}
+		if e.Thumbnail != "" {
+			item.Enclosure = &rssEnclosure{URL: e.Thumbnail, Type: thumbnailType(e.Thumbnail)}
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	return rss{Version: "2.0", DC: "http://purl.org/dc/elements/1.1/", Atom: "http://www.w3.org/2005/Atom", Channel: channel}
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Author    *atomAuthor `xml:"author"`
+	Summary   string      `xml:"summary,omitempty"`
+	Links     []atomLink  `xml:"link"`
+}
+
+func renderAtom(f feed) interface{} {
+	a := atomFeed{
+		ID:      f.SelfURL,
+		Title:   f.Title,
+		Updated: f.Updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: "lighthouse"},
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: WebURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:      e.LbryURL,
+			Title:   e.Title,
+			Updated: f.Updated.Format(time.RFC3339),
+			Summary: e.Description,
+			Links:   []atomLink{{Href: e.WebURL, Rel: "alternate", Type: "text/html"}},
+		}
+		if !e.Released.IsZero() {
+			entry.Updated = e.Released.Format(time.RFC3339)
+			entry.Published = entry.Updated
+		}
+		if e.Channel != "" {
+			entry.Author = &atomAuthor{Name: e.Channel}
+		}
+		if e.Thumbnail != "" {
+			entry.Links = append(entry.Links, atomLink{Href: e.Thumbnail, Rel: "enclosure", Type: thumbnailType(e.Thumbnail)})
+		}
+		a.Entries = append(a.Entries, entry)
+	}
+	return a
+}
@@ -4,24 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/cache"
 	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/httpcache"
 	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/timeout"
+	"github.com/lbryio/lighthouse/app/tracing"
 	"github.com/lbryio/lighthouse/app/validator"
 
 	"github.com/lbryio/lbry.go/v2/extras/api"
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	v "github.com/lbryio/ozzo-validation"
 
-	"github.com/karlseguin/ccache"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"gopkg.in/olivere/elastic.v6"
 )
 
-var searchCache = ccache.New(ccache.Configure().MaxSize(10000))
+var searchCache = cache.New("search", 10000, 5*time.Minute, 10*time.Minute)
 
 type searchRequest struct {
 	S         string
@@ -53,17 +61,15 @@ func Search(r *http.Request) api.Response {
 	start := time.Now()
 	searchRequest := searchRequest{}
 
-	err := api.FormValues(r, &searchRequest, []*v.FieldRules{
-		v.Field(&searchRequest.S, v.Length(3, 99999), v.Required),
-		v.Field(&searchRequest.Size, v.Max(10000)),
-		v.Field(&searchRequest.From, v.Max(9999)),
-		//There is a bug in the app https://github.com/lbryio/lbry-desktop/issues/3377
-		//v.Field(&searchRequest.ClaimType, validator.ClaimTypeValidator),
-		v.Field(&searchRequest.MediaType, validator.MediaTypeValidator),
-	})
+	err := api.FormValues(r, &searchRequest, searchRequest.rules())
 	if err != nil {
 		return api.Response{Error: errors.Err(err), Status: http.StatusBadRequest}
 	}
+	caller := auth.FromRequest(r)
+	err = caller.CheckLimits(searchRequest.Size, searchRequest.From, searchRequest.Debug || searchRequest.Source || searchRequest.Score)
+	if err != nil {
+		return api.Response{Error: errors.Err(err)}
+	}
 	searchRequest.searchType = "general"
 	searchRequest.S = truncate(searchRequest.S)
 	searchRequest.S = checkForSpecialHandling(searchRequest.S)
@@ -71,79 +77,212 @@ func Search(r *http.Request) api.Response {
 	if searchRequest.RelatedTo != nil {
 		searchRequest.searchType = "related_content"
 	}
+	searchRequest.recordFilterUsage()
 	query := searchRequest.newQuery()
 	t, err := query.Source()
 	if err != nil {
 		return api.Response{Error: errors.Err("%s: for query -s %s", err, t)}
 	}
-	includes := []string{"name", "claimId"}
-	if searchRequest.Include != nil {
-		additionfields := strings.Split(*searchRequest.Include, ",")
-		includes = append(includes, additionfields...)
-	}
-	sourceContext := elastic.NewFetchSourceContext(true).Exclude("value")
-	if !searchRequest.Source {
-		includes = append(includes)
-		sourceContext = sourceContext.Include(includes...)
-		if searchRequest.Resolve {
-			sourceContext = sourceContext.Include("channel", "channel_claim_id", "title", "thumbnail_url", "release_time", "fee", "nsfw", "duration")
-		}
-	}
 	service := es.Client.
 		Search("claims").
 		Query(query).
-		FetchSourceContext(sourceContext)
+		FetchSourceContext(searchRequest.sourceContext()).
+		Header(accesslog.OpaqueIDHeader, accesslog.RequestID(r))
 	if searchRequest.Size != nil {
 		service = service.Size(*searchRequest.Size)
 	}
 	if searchRequest.From != nil {
 		service = service.From(*searchRequest.From)
 	}
+	if shards, ok := timeout.Shards(r.Context()); ok {
+		service = service.Timeout(shards)
+	}
 
 	if searchRequest.Debug {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return api.Response{Error: breaker.Unavailable("elasticsearch")}
+		}
+		esStart := time.Now()
 		searchResults, err := service.
 			Explain(true).
 			ErrorTrace(true).
-			Do(context.Background())
+			Do(r.Context())
+		es.Breaker.Record(time.Since(esStart), err)
 		if err != nil {
-			return api.Response{Error: errors.Err(err)}
+			return api.Response{Error: errors.Err(timeout.Error(err))}
 		}
+		if partial, timedOut := es.Partial(searchResults); partial {
+			timeout.SetPartial(r, timedOut)
+		}
+		accesslog.SetTook(r, searchResults.TookInMillis)
+		accesslog.SetResults(r, len(searchResults.Hits.Hits))
 		return api.Response{Data: searchResults}
 	}
 	if searchRequest.SortBy != nil {
 		sortBy := strings.TrimPrefix(*searchRequest.SortBy, "^")
 		service.Sort(sortBy, strings.Contains(*searchRequest.SortBy, "^"))
 	}
-	results, err := searchCache.Fetch(r.URL.RequestURI(), 5*time.Minute, func() (interface{}, error) {
-		searchResults, err := service.Do(context.Background())
+	key := cache.Key(r, "s")
+	value, cacheStatus, err := searchCache.Fetch(r.Context(), key, func(ctx context.Context) (interface{}, error) {
+		err := es.Breaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+		esStart := time.Now()
+		searchResults, err := service.Do(ctx)
+		es.Breaker.Record(time.Since(esStart), err)
 		if err != nil {
 			return nil, errors.Err(err)
 		}
-		results := make([]map[string]interface{}, 0)
-		for _, hit := range searchResults.Hits.Hits {
-			if hit.Source != nil {
-				data, err := hit.Source.MarshalJSON()
-				if err != nil {
-					logrus.Error(err)
-					continue
-				}
-				result := map[string]interface{}{}
-				err = json.Unmarshal(data, &result)
-				if err != nil {
-					logrus.Error(err)
-					continue
-				}
-				results = append(results, result)
-			}
+		metrics.ES(esStart, searchRequest.searchType, searchResults.TookInMillis, searchResults.Hits.TotalHits)
+		_, span := tracing.Start(ctx, "search.decode", attribute.Int("hits", len(searchResults.Hits.Hits)))
+		defer span.End()
+		results := decodeHits(searchResults.Hits.Hits)
+		partial, timedOut := es.Partial(searchResults)
+		if partial {
+			metrics.Incomplete(searchRequest.searchType, timedOut)
+			return cache.NoStore(searchResult{hits: results, took: searchResults.TookInMillis, partial: partial,
+				timedOut: timedOut}), nil
 		}
-		return results, nil
+		return searchResult{hits: results, took: searchResults.TookInMillis}, nil
 	})
+	served := string(cacheStatus)
+	if errors.Is(err, breaker.ErrOpen) {
+		stale, ok := searchCache.Peek(key)
+		value, err = breaker.Stale(r, "elasticsearch", searchRequest.searchType, stale, ok)
+		served = "degraded"
+	}
 	if err != nil {
-		return api.Response{Error: errors.Err(err)}
+		return api.Response{Error: errors.Err(timeout.Error(err))}
+	}
+	results := value.(searchResult)
+	if results.partial {
+		timeout.SetPartial(r, results.timedOut)
+	}
+	returned := len(results.hits)
+	accesslog.SetTook(r, results.took)
+	accesslog.SetCache(r, served)
+	accesslog.SetResults(r, returned)
+	metrics.Results(searchRequest.searchType, searchRequest.terms, returned)
+	for _, result := range results.hits {
+		if claimID, ok := result["claimId"].(string); ok {
+			httpcache.AddClaims(r, claimID)
+		}
 	}
 	metrics.SearchDuration.WithLabelValues(
 		searchRequest.searchType,
 		strconv.Itoa(searchRequest.terms)).
 		Observe(time.Since(start).Seconds())
-	return api.Response{Data: results.Value()}
+	return api.Response{Data: results.hits}
+}
+
+// Query returns the query Search runs for the parameters, which are validated the same way. Saved searches use it to
+// find the newly indexed claims they match.
+func Query(params url.Values) (elastic.Query, error) {
+	r, err := http.NewRequest(http.MethodGet, "/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	searchRequest := searchRequest{}
+	err = api.FormValues(r, &searchRequest, searchRequest.rules())
+	if err != nil {
+		return nil, api.StatusError{Status: http.StatusBadRequest, Err: errors.Err(err)}
+	}
+	if searchRequest.Debug || searchRequest.Source || searchRequest.Score {
+		return nil, api.StatusError{Status: http.StatusBadRequest,
+			Err: errors.Err("the debug parameters are not supported by saved searches")}
+	}
+	searchRequest.S = truncate(searchRequest.S)
+	searchRequest.S = checkForSpecialHandling(searchRequest.S)
+	return searchRequest.newQuery(), nil
+}
+
+// sourceContext returns the fields of the claims to return, the name and claim id unless more are requested with
+// include, resolve or source.
+func (r searchRequest) sourceContext() *elastic.FetchSourceContext {
+	includes := []string{"name", "claimId"}
+	if r.Include != nil {
+		additionfields := strings.Split(*r.Include, ",")
+		includes = append(includes, additionfields...)
+	}
+	sourceContext := elastic.NewFetchSourceContext(true).Exclude("value")
+	if !r.Source {
+		sourceContext = sourceContext.Include(includes...)
+		if r.Resolve {
+			sourceContext = sourceContext.Include("channel", "channel_claim_id", "title", "thumbnail_url", "release_time", "fee", "nsfw", "duration")
+		}
+	}
+	return sourceContext
+}
+
+// decodeHits returns the sources of the hits, skipping those that cannot be decoded.
+func decodeHits(hits []*elastic.SearchHit) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		if hit.Source != nil {
+			data, err := hit.Source.MarshalJSON()
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			result := map[string]interface{}{}
+			err = json.Unmarshal(data, &result)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// searchResult is the cached result of a search. Results of searches that did not complete on every shard are
+// returned but not cached.
+type searchResult struct {
+	hits []map[string]interface{}
+	// took is the time elasticsearch spent on the search the results were fetched with.
+	took     int64
+	partial  bool
+	timedOut bool
+}
+
+func (r *searchRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.S, v.Length(3, 99999), v.Required),
+		v.Field(&r.Size, v.Max(10000)),
+		v.Field(&r.From, v.Max(9999)),
+		//There is a bug in the app https://github.com/lbryio/lbry-desktop/issues/3377
+		//v.Field(&r.ClaimType, validator.ClaimTypeValidator),
+		v.Field(&r.MediaType, validator.MediaTypeValidator),
+	}
+}
+
+// Params returns the parameters of Search and their validation rules, used to document the api.
+func Params() (interface{}, []*v.FieldRules) {
+	r := &searchRequest{}
+	return r, r.rules()
+}
+
+// recordFilterUsage counts the filters used by the request so we know which ones are worth optimizing.
+func (r searchRequest) recordFilterUsage() {
+	filters := map[string]bool{
+		"nsfw":         r.NSFW != nil,
+		"free_only":    r.FreeOnly != nil,
+		"content_type": r.ContentType != nil,
+		"media_type":   r.MediaType != nil,
+		"claim_type":   r.ClaimType != nil,
+		"channel":      r.Channel != nil,
+		"channel_id":   r.ChannelID != nil,
+		"claim_id":     r.ClaimID != nil,
+		"related_to":   r.RelatedTo != nil,
+		"sort_by":      r.SortBy != nil,
+		"resolve":      r.Resolve,
+	}
+	for filter, used := range filters {
+		if used {
+			metrics.FilterUsage.WithLabelValues(filter).Inc()
+		}
+	}
 }
@@ -6,8 +6,15 @@ import (
 
 	"github.com/lbryio/lighthouse/meta"
 
+	"github.com/lbryio/lighthouse/app/admin"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/cache"
 	"github.com/lbryio/lighthouse/app/es"
 	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/jobs"
+	"github.com/lbryio/lighthouse/app/jobs/chainquery"
+	"github.com/lbryio/lighthouse/app/timeout"
 	"gopkg.in/olivere/elastic.v6"
 
 	"github.com/lbryio/lbry.go/v2/extras/api"
@@ -19,38 +26,71 @@ type status struct {
 	SemanticVersion string
 	VersionLong     string
 	VersionMsg      string
+	Breaker         breaker.Status
 	Health          elastic.CatHealthResponse
 	ClaimCount      elastic.CatCountResponse
 	Allocations     elastic.CatAllocationResponse
 	ClaimStats      elastic.IndicesStatsResponse
 }
 
-// Status returns the status of lighthouse including version information and elastic search state.
+// Status returns the status of lighthouse including version information, the state of the elasticsearch circuit
+// breaker and elastic search state. The version and breaker state are returned even when elastic search fails.
 func Status(r *http.Request) api.Response {
-	health, err := es.Client.CatHealth().Do(context.Background())
+	s := status{
+		Version:         meta.GetVersion(),
+		SemanticVersion: meta.GetSemVersion(),
+		VersionLong:     meta.GetVersionLong(),
+		VersionMsg:      meta.GetCommitMessage(),
+		Breaker:         es.Breaker.Status(),
+	}
+	var err error
+	s.Health, err = es.Client.CatHealth().Do(r.Context())
 	if err != nil {
-		return api.Response{Error: errors.Err(err)}
+		return api.Response{Error: errors.Err(timeout.Error(err)), Data: s}
 	}
-	counts, err := es.Client.CatCount().Index(index.Claims).Do(context.Background())
+	s.ClaimCount, err = es.Client.CatCount().Index(index.Claims).Do(r.Context())
 	if err != nil {
-		return api.Response{Error: errors.Err(err)}
+		return api.Response{Error: errors.Err(timeout.Error(err)), Data: s}
 	}
-	alloc, err := es.Client.CatAllocation().Human(true).Do(context.Background())
+	s.Allocations, err = es.Client.CatAllocation().Human(true).Do(r.Context())
 	if err != nil {
-		return api.Response{Error: errors.Err(err)}
+		return api.Response{Error: errors.Err(timeout.Error(err)), Data: s}
 	}
-	claimStats, err := es.Client.IndexStats(index.Claims).Human(true).Do(context.Background())
+	claimStats, err := es.Client.IndexStats(index.Claims).Human(true).Do(r.Context())
 	if err != nil {
-		return api.Response{Error: errors.Err(err)}
+		return api.Response{Error: errors.Err(timeout.Error(err)), Data: s}
 	}
+	s.ClaimStats = *claimStats
 
-	return api.Response{Data: status{
-		Version:         meta.GetVersion(),
-		SemanticVersion: meta.GetSemVersion(),
-		VersionLong:     meta.GetVersionLong(),
-		VersionMsg:      meta.GetCommitMessage(),
-		Health:          health,
-		ClaimCount:      counts,
-		Allocations:     alloc,
-		ClaimStats:      *claimStats}}
+	return api.Response{Data: s}
+}
+
+type jobsStatus struct {
+	Jobs           []jobs.Status
+	ClaimSync      chainquery.SyncState
+	ClaimSyncError string `json:",omitempty"`
+	BulkProcessors []es.BulkStats
+	Caches         []cache.Stats
+}
+
+// JobsStatus returns the history of the background jobs, the state of the claim sync, the stats of the bulk
+// processors used by the jobs and the stats of the response caches. It exposes internal errors and queries
+// Chainquery, so it requires an internal api key or an admin token.
+func JobsStatus(r *http.Request) api.Response {
+	if _, ok := admin.Authorize(r); !ok && !auth.FromRequest(r).Tier.Debug {
+		return api.Response{Error: errors.Err("the jobs status requires an internal api key"), Status: http.StatusForbidden}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+	status := jobsStatus{
+		Jobs:           jobs.GetStatus(),
+		BulkProcessors: es.GetBulkStats(),
+		Caches:         cache.AllStats(),
+	}
+	var err error
+	status.ClaimSync, err = chainquery.GetSyncState(ctx)
+	if err != nil {
+		status.ClaimSyncError = err.Error()
+	}
+	return api.Response{Data: status}
 }
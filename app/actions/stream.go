@@ -0,0 +1,209 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/actions/search"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/ratelimit"
+	"github.com/lbryio/lighthouse/app/stream"
+	"github.com/lbryio/lighthouse/app/validator"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	v "github.com/lbryio/ozzo-validation"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// StreamHeartbeat is the interval at which comments are sent on idle streams to keep proxies from closing them.
+	StreamHeartbeat = 15 * time.Second
+	// StreamRetry is the reconnection delay suggested to clients.
+	StreamRetry = 3 * time.Second
+)
+
+type streamRequest struct {
+	ChannelIDs  *string `json:"channel_ids"`
+	Tags        *string
+	ClaimType   *string `json:"claimType"`
+	S           *string
+	LastEventID *string `json:"last_event_id"`
+}
+
+func (r *streamRequest) rules() []*v.FieldRules {
+	return []*v.FieldRules{
+		v.Field(&r.ChannelIDs, validator.ClaimIDsValidator),
+		v.Field(&r.Tags, v.Length(1, 0)),
+		v.Field(&r.ClaimType, v.In("file", "channel")),
+		v.Field(&r.S, v.Length(1, 0)),
+	}
+}
+
+// filter returns the filter of the stream, the search expression is validated the way /search does.
+func (r *streamRequest) filter() (stream.Filter, error) {
+	f := stream.Filter{}
+	if r.ChannelIDs != nil {
+		ids := strings.Split(*r.ChannelIDs, ",")
+		if len(ids) > search.MaxFeedChannels {
+			return f, errors.Err("channel_ids: at most %d channels can be streamed", search.MaxFeedChannels)
+		}
+		f.ChannelIDs = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			id = strings.ToLower(strings.TrimSpace(id))
+			if len(id) != claimIDLength {
+				return f, errors.Err("channel_ids: %s is not a full claim id", id)
+			}
+			f.ChannelIDs[id] = true
+		}
+	}
+	if r.Tags != nil {
+		f.Tags = make(map[string]bool)
+		for _, tag := range strings.Split(*r.Tags, ",") {
+			f.Tags[strings.TrimSpace(tag)] = true
+		}
+	}
+	if r.ClaimType != nil {
+		f.ClaimType = map[string]string{"file": "stream", "channel": "channel"}[*r.ClaimType]
+	}
+	if r.S != nil {
+		query, err := search.Query(url.Values{"s": {*r.S}})
+		if err != nil {
+			return f, err
+		}
+		f.Query = query
+	}
+	return f, nil
+}
+
+// ClaimStream streams the claims indexed by the claim sync as server-sent events, filtered by channel_ids, tags,
+// claimType and a search expression. Each claim is sent as a `claim` event. Reconnecting clients sending the
+// Last-Event-ID header, or the last_event_id parameter, first receive the buffered events they missed, preceded by a
+// `reset` event if some of them are no longer buffered. Streams of clients falling too far behind are closed, they
+// catch up from the buffer when reconnecting. Each api key, or client ip without one, can have stream.MaxPerClient
+// streams open.
+func ClaimStream(w http.ResponseWriter, r *http.Request) {
+	req := streamRequest{}
+	err := api.FormValues(r, &req, req.rules())
+	if err != nil {
+		streamError(w, r, http.StatusBadRequest, errors.Err(err))
+		return
+	}
+	filter, err := req.filter()
+	if err != nil {
+		streamError(w, r, http.StatusBadRequest, errors.Err(err))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		streamError(w, r, http.StatusInternalServerError, errors.Err("streaming is not supported"))
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" && req.LastEventID != nil {
+		lastEventID = *req.LastEventID
+	}
+	client := "ip:" + ratelimit.ClientIP(r)
+	if caller := auth.FromRequest(r); caller.Name != "" {
+		client = "key:" + caller.Name
+	}
+	sub, backlog, caughtUp, err := stream.Subscribe(filter, client, lastEventID)
+	if errors.Is(err, stream.ErrClientLimit) {
+		streamError(w, r, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil {
+		streamError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer stream.Unsubscribe(sub)
+
+	for k, v := range api.ResponseHeaders {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// Keep nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write([]byte("retry: " + strconv.FormatInt(StreamRetry.Milliseconds(), 10) + "\n\n"))
+	if err == nil && !caughtUp {
+		err = writeEvent(w, "", "reset", map[string]string{"reason": "events after " + lastEventID + " are no longer buffered"})
+	}
+	if err == nil {
+		err = writeEvents(w, r, sub, backlog)
+	}
+	if err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Closed:
+			if sub.Overflowed {
+				_ = writeEvent(w, "", "overflow", map[string]string{"reason": "the client fell behind, reconnect to catch up"})
+				flusher.Flush()
+			}
+			return
+		case batch := <-sub.Next():
+			sub.Done(batch)
+			err = writeEvents(w, r, sub, batch)
+		case <-heartbeat.C:
+			_, err = w.Write([]byte(": heartbeat\n\n"))
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvents writes the events selected by the query of the subscriber. If the query cannot be evaluated an error
+// event is sent and the stream closed, the client catches up when reconnecting.
+func writeEvents(w http.ResponseWriter, r *http.Request, sub *stream.Subscriber, batch []stream.Event) error {
+	selected, err := sub.Select(r.Context(), batch)
+	if err != nil {
+		logrus.Error(errors.Prefix("claim stream", err))
+		_ = writeEvent(w, "", "error", map[string]string{"reason": "the search expression could not be evaluated"})
+		return err
+	}
+	for _, e := range selected {
+		err := writeEvent(w, e.ID(), "claim", e.Claim)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeEvent(w http.ResponseWriter, id, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return errors.Err(err)
+	}
+	var msg []byte
+	if id != "" {
+		msg = append(msg, "id: "+id+"\n"...)
+	}
+	msg = append(msg, "event: "+event+"\ndata: "...)
+	msg = append(msg, b...)
+	msg = append(msg, "\n\n"...)
+	_, err = w.Write(msg)
+	return err
+}
+
+func streamError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	api.Handler(func(r *http.Request) api.Response {
+		return api.Response{Error: err, Status: status}
+	}).ServeHTTP(w, r)
+}
@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/es"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+const (
+	// TokenHeader is the header used to present an admin token, as `Bearer <token>`.
+	TokenHeader = "Authorization"
+	// AuditLog is the name of the index the audit log of the admin actions is stored in.
+	AuditLog  = "admin_audit"
+	auditType = "entry"
+)
+
+var (
+	mu sync.RWMutex
+	// tokens holds the names of the admin tokens by the sha256 of the token.
+	tokens = make(map[[sha256.Size]byte]string)
+
+	logger = &logrus.Logger{
+		Out:       os.Stdout,
+		Formatter: &logrus.JSONFormatter{},
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.InfoLevel,
+	}
+)
+
+// Entry is an entry of the audit log, an admin action and its outcome.
+type Entry struct {
+	At        time.Time   `json:"at"`
+	Actor     string      `json:"actor"`
+	Action    string      `json:"action"`
+	Params    interface{} `json:"params,omitempty"`
+	Outcome   string      `json:"outcome"`
+	Error     string      `json:"error,omitempty"`
+	Remote    string      `json:"remote"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Outcomes of an admin action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeStarted = "started"
+	OutcomeError   = "error"
+)
+
+// ParseTokens registers the admin tokens in the form `name:token,name2:token2`. The name identifies who used the
+// token in the audit log.
+func ParseTokens(list string) error {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errors.Err("invalid admin token entry, expected name:token")
+		}
+		AddToken(parts[0], parts[1])
+	}
+	return nil
+}
+
+// AddToken registers an admin token.
+func AddToken(name, token string) {
+	mu.Lock()
+	defer mu.Unlock()
+	tokens[sha256.Sum256([]byte(token))] = name
+}
+
+// RemoveToken unregisters an admin token.
+func RemoveToken(token string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(tokens, sha256.Sum256([]byte(token)))
+}
+
+// Authorize returns who is making the request, from the verified client certificate of a mutual TLS connection or
+// the admin token presented, and false if neither authorizes it.
+func Authorize(r *http.Request) (string, bool) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+	}
+	token := strings.TrimPrefix(r.Header.Get(TokenHeader), "Bearer ")
+	if token == "" {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(token))
+	mu.RLock()
+	defer mu.RUnlock()
+	for known, name := range tokens {
+		if subtle.ConstantTimeCompare(sum[:], known[:]) == 1 {
+			return "token:" + name, true
+		}
+	}
+	return "", false
+}
+
+// Audit records the admin action in the audit log. Entries are always written to the log output, and stored in
+// elasticsearch to be listed with Entries.
+func Audit(r *http.Request, actor, action string, params interface{}, outcome string, err error) {
+	e := Entry{
+		At:        time.Now().UTC(),
+		Actor:     actor,
+		Action:    action,
+		Params:    params,
+		Outcome:   outcome,
+		Remote:    r.RemoteAddr,
+		RequestID: accesslog.RequestID(r),
+	}
+	if err != nil {
+		e.Outcome = OutcomeError
+		e.Error = err.Error()
+	}
+	p, _ := json.Marshal(params)
+	logger.WithFields(logrus.Fields{
+		"actor":      e.Actor,
+		"action":     e.Action,
+		"params":     string(p),
+		"outcome":    e.Outcome,
+		"error":      e.Error,
+		"remote":     e.Remote,
+		"request_id": e.RequestID,
+	}).Info("admin")
+	if es.Client == nil {
+		return
+	}
+	_, esErr := es.Client.Index().Index(AuditLog).Type(auditType).BodyJson(e).Do(context.Background())
+	if esErr != nil {
+		logrus.Error(errors.Prefix("admin audit log", esErr))
+	}
+}
+
+// Entries returns the latest entries of the audit log, newest first.
+func Entries(ctx context.Context, size int) ([]Entry, error) {
+	res, err := es.Client.Search(AuditLog).Sort("at", false).Size(size).Do(ctx)
+	entries := make([]Entry, 0)
+	if elastic.IsNotFound(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	for _, hit := range res.Hits.Hits {
+		var e Entry
+		if hit.Source == nil || json.Unmarshal(*hit.Source, &e) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
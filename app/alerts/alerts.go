@@ -0,0 +1,222 @@
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/actions/search"
+	"github.com/lbryio/lighthouse/app/es"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"gopkg.in/olivere/elastic.v6"
+)
+
+const (
+	// SavedSearches is the name of the index saved searches are stored in.
+	SavedSearches   = "saved_searches"
+	savedSearchType = "saved_search"
+	// Deliveries is the name of the index the delivery log of the alerts is stored in.
+	Deliveries   = "alert_deliveries"
+	deliveryType = "delivery"
+)
+
+// MaxSavedSearches is the largest number of saved searches an API key can register.
+var MaxSavedSearches = 100
+
+// SavedSearch is a search whose newly indexed matches are posted to a webhook.
+type SavedSearch struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Owner is the name of the API key that registered the search.
+	Owner string `json:"owner"`
+	// Params are the parameters of the search, as sent to /search.
+	Params     map[string]string `json:"params"`
+	WebhookURL string            `json:"webhook_url"`
+	// Secret signs the payloads posted to the webhook. It is only returned when the search is registered.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is an entry of the delivery log, the alert of the claims matching a saved search and the attempts made
+// to post it to the webhook.
+type Delivery struct {
+	ID        string    `json:"id"`
+	SearchID  string    `json:"search_id"`
+	ClaimIDs  []string  `json:"claim_ids"`
+	Status    string    `json:"status"`
+	Attempts  []Attempt `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Statuses of a delivery.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Attempt is a single post of an alert to a webhook.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// query returns the query the saved search runs, validating its parameters the way /search does.
+func (s SavedSearch) query() (elastic.Query, error) {
+	params := url.Values{}
+	for k, v := range s.Params {
+		params.Set(k, v)
+	}
+	return search.Query(params)
+}
+
+// Register validates and stores the saved search, assigning its id and signing secret.
+func Register(ctx context.Context, s SavedSearch) (SavedSearch, error) {
+	if strings.TrimSpace(s.Name) == "" {
+		return s, badRequest("name: cannot be blank")
+	}
+	_, err := s.query()
+	if err != nil {
+		return s, err
+	}
+	webhook, err := url.Parse(s.WebhookURL)
+	if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+		return s, badRequest("webhook_url: must be an absolute http or https url")
+	}
+	existing, err := List(ctx, s.Owner)
+	if err != nil {
+		return s, err
+	}
+	if len(existing) >= MaxSavedSearches {
+		return s, badRequest("at most %d searches can be saved per api key", MaxSavedSearches)
+	}
+	s.ID = randomHex(16)
+	s.Secret = randomHex(32)
+	s.CreatedAt = time.Now().UTC()
+	_, err = es.Client.Index().Index(SavedSearches).Type(savedSearchType).Id(s.ID).BodyJson(s).Refresh("wait_for").Do(ctx)
+	if err != nil {
+		return s, errors.Err(err)
+	}
+	return s, nil
+}
+
+// Get returns the saved search with the id, and false if there is none.
+func Get(ctx context.Context, id string) (SavedSearch, bool, error) {
+	res, err := es.Client.Get().Index(SavedSearches).Type(savedSearchType).Id(id).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return SavedSearch{}, false, nil
+	}
+	if err != nil {
+		return SavedSearch{}, false, errors.Err(err)
+	}
+	var s SavedSearch
+	if !res.Found || res.Source == nil {
+		return s, false, nil
+	}
+	err = json.Unmarshal(*res.Source, &s)
+	if err != nil {
+		return s, false, errors.Err(err)
+	}
+	return s, true, nil
+}
+
+// List returns the searches saved by the owner, or all of them if owner is empty, without their secrets.
+func List(ctx context.Context, owner string) ([]SavedSearch, error) {
+	searches, err := load(ctx, owner)
+	for i := range searches {
+		searches[i].Secret = ""
+	}
+	return searches, err
+}
+
+func load(ctx context.Context, owner string) ([]SavedSearch, error) {
+	query := elastic.Query(elastic.NewMatchAllQuery())
+	if owner != "" {
+		query = elastic.NewTermQuery("owner.keyword", owner)
+	}
+	var searches []SavedSearch
+	scroll := es.Client.Scroll(SavedSearches).Type(savedSearchType).Query(query).Size(500)
+	defer func() {
+		_ = scroll.Clear(context.Background())
+	}()
+	for {
+		res, err := scroll.Do(ctx)
+		if err == io.EOF || elastic.IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		for _, hit := range res.Hits.Hits {
+			var s SavedSearch
+			if hit.Source == nil || json.Unmarshal(*hit.Source, &s) != nil {
+				continue
+			}
+			searches = append(searches, s)
+		}
+	}
+	return searches, nil
+}
+
+// Delete removes the saved search, its delivery log is kept.
+func Delete(ctx context.Context, id string) error {
+	_, err := es.Client.Delete().Index(SavedSearches).Type(savedSearchType).Id(id).Refresh("wait_for").Do(ctx)
+	if err != nil && !elastic.IsNotFound(err) {
+		return errors.Err(err)
+	}
+	return nil
+}
+
+// DeliveryLog returns the latest deliveries of the saved search, newest first.
+func DeliveryLog(ctx context.Context, searchID string, size int) ([]Delivery, error) {
+	res, err := es.Client.Search(Deliveries).
+		Query(elastic.NewTermQuery("search_id.keyword", searchID)).
+		Sort("created_at", false).
+		Size(size).
+		Do(ctx)
+	deliveries := make([]Delivery, 0)
+	if elastic.IsNotFound(err) {
+		return deliveries, nil
+	}
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	for _, hit := range res.Hits.Hits {
+		var d Delivery
+		if hit.Source == nil || json.Unmarshal(*hit.Source, &d) != nil {
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func logDelivery(ctx context.Context, d Delivery) error {
+	_, err := es.Client.Index().Index(Deliveries).Type(deliveryType).Id(d.ID).BodyJson(d).Refresh("wait_for").Do(ctx)
+	return errors.Err(err)
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return api.StatusError{Status: http.StatusBadRequest, Err: errors.Err(format, args...)}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,204 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SignatureHeader holds the signature of the payload, `sha256=` followed by the hex encoded HMAC-SHA256 of
+	// the timestamp, a dot and the body, keyed with the secret of the saved search.
+	SignatureHeader = "X-Lighthouse-Signature"
+	// TimestampHeader holds the unix time the payload was signed at, receivers should reject old ones.
+	TimestampHeader = "X-Lighthouse-Timestamp"
+	// DeliveryHeader holds the id of the delivery, which is the same across its retries.
+	DeliveryHeader = "X-Lighthouse-Delivery"
+)
+
+var (
+	// MaxAttempts is the number of times an alert is posted before its delivery is marked as failed.
+	MaxAttempts = 5
+	// RetryBackoff is the wait before the first retry, it doubles with each retry up to MaxBackoff.
+	RetryBackoff = 10 * time.Second
+	// MaxBackoff is the longest wait between two attempts.
+	MaxBackoff = 10 * time.Minute
+	// AllowPrivateWebhooks allows webhooks on loopback and private addresses, which are refused by default so saved
+	// searches cannot be used to reach internal services.
+	AllowPrivateWebhooks = false
+
+	stopper = stop.New()
+	client  = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// No proxy, the addresses connected to are the ones checked.
+			DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: checkAddress}).DialContext,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	privateNetworks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+)
+
+// Payload is the json body posted to webhooks.
+type Payload struct {
+	DeliveryID string                   `json:"delivery_id"`
+	Search     SavedSearch              `json:"search"`
+	Claims     []map[string]interface{} `json:"claims"`
+}
+
+// Sign returns the signature of the body sent at the unix timestamp, as set in the SignatureHeader.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Shutdown interrupts the deliveries waiting to be retried, marking them as failed, and waits for the others.
+func Shutdown() {
+	stopper.StopAndWait()
+}
+
+// deliver posts the claims of the pending delivery to the webhook of the saved search in the background, retrying
+// with exponential backoff, and records its attempts in the delivery log.
+func deliver(s SavedSearch, d Delivery, claims []map[string]interface{}) {
+	stopper.Add(1)
+	go func() {
+		defer stopper.Done()
+		ctx := context.Background()
+		secret := s.Secret
+		s.Secret = ""
+		body, err := json.Marshal(Payload{DeliveryID: d.ID, Search: s, Claims: claims})
+		if err != nil {
+			logrus.Error(errors.Err(err))
+			d.Status = StatusFailed
+			d.Attempts = append(d.Attempts, Attempt{At: time.Now().UTC(), Error: err.Error()})
+		}
+
+		backoff := RetryBackoff
+	attempts:
+		for err == nil && len(d.Attempts) < MaxAttempts {
+			attempt, retry := post(s.WebhookURL, secret, d.ID, body)
+			d.Attempts = append(d.Attempts, attempt)
+			if attempt.Error == "" {
+				d.Status = StatusDelivered
+				metrics.AlertAttempts.WithLabelValues("delivered").Inc()
+				break
+			}
+			metrics.AlertAttempts.WithLabelValues("failed").Inc()
+			if !retry || len(d.Attempts) == MaxAttempts {
+				break
+			}
+			select {
+			case <-stopper.Ch():
+				d.Attempts = append(d.Attempts, Attempt{At: time.Now().UTC(), Error: "interrupted by shutdown"})
+				break attempts
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > MaxBackoff {
+				backoff = MaxBackoff
+			}
+		}
+		if d.Status != StatusDelivered {
+			d.Status = StatusFailed
+			logrus.Warningf("alert %s of saved search %s failed after %d attempts: %s", d.ID, s.ID, len(d.Attempts),
+				d.Attempts[len(d.Attempts)-1].Error)
+		}
+		metrics.AlertDeliveries.WithLabelValues(d.Status).Inc()
+		d.UpdatedAt = time.Now().UTC()
+		err = logDelivery(ctx, d)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+}
+
+// post makes a single attempt to post the body to the webhook, and returns whether it is worth retrying if it
+// failed. Client errors other than timeouts and rate limits are not retried.
+func post(webhook, secret, deliveryID string, body []byte) (Attempt, bool) {
+	start := time.Now()
+	attempt := Attempt{At: start.UTC()}
+	req, err := http.NewRequest(http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, false
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lighthouse-alerts")
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+	resp, err := client.Do(req)
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, true
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	_ = resp.Body.Close()
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return attempt, false
+	}
+	attempt.Error = "webhook responded with " + resp.Status
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+	return attempt, retry
+}
+
+// checkAddress refuses connections to loopback, private and link local addresses unless AllowPrivateWebhooks is set.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	if AllowPrivateWebhooks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Err(err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Err("webhook address %s is not an ip", host)
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return errors.Err("webhook address %s is not public", host)
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return errors.Err("webhook address %s is not public", host)
+		}
+	}
+	return nil
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}
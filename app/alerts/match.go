@@ -0,0 +1,193 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+const (
+	// matchBatch is the number of saved searches evaluated in a single multi search.
+	matchBatch = 100
+	// maxAlertClaims is the largest number of claims sent in a single alert. The claims indexed are matched in chunks
+	// of this size, so that a search matching more of them is sent several alerts.
+	maxAlertClaims = 100
+)
+
+var alertFields = []string{"name", "claimId", "title", "channel", "channel_claim_id", "thumbnail_url", "release_time",
+	"content_type", "claim_type"}
+
+// Match evaluates every saved search against the claims that were just indexed and posts the claims each one matches
+// to its webhook. Claims already delivered for a search are not sent again, so claims updated after being alerted on
+// do not alert twice.
+func Match(claimIDs []string) {
+	if len(claimIDs) == 0 || es.Client == nil {
+		return
+	}
+	defer metrics.Job(time.Now(), "alerts_match")
+	ctx := context.Background()
+	err := match(ctx, claimIDs)
+	if err != nil {
+		logrus.Error(errors.Prefix("saved search alerts", err))
+	}
+}
+
+func match(ctx context.Context, claimIDs []string) error {
+	searches, err := load(ctx, "")
+	if err != nil || len(searches) == 0 {
+		return err
+	}
+	// Make the claims that were just indexed searchable.
+	_, err = es.Client.Refresh(index.Claims).Do(ctx)
+	if err != nil {
+		return errors.Err(err)
+	}
+	for start := 0; start < len(claimIDs); start += maxAlertClaims {
+		end := start + maxAlertClaims
+		if end > len(claimIDs) {
+			end = len(claimIDs)
+		}
+		err := matchClaims(ctx, searches, claimIDs[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchClaims alerts the saved searches matching any of the claims, at most maxAlertClaims of them.
+func matchClaims(ctx context.Context, searches []SavedSearch, claimIDs []string) error {
+	ids := make([]interface{}, len(claimIDs))
+	for i, id := range claimIDs {
+		ids[i] = id
+	}
+	for start := 0; start < len(searches); start += matchBatch {
+		end := start + matchBatch
+		if end > len(searches) {
+			end = len(searches)
+		}
+		batch := searches[start:end]
+		service := es.Client.MultiSearch()
+		var queried []SavedSearch
+		for _, s := range batch {
+			query, err := s.query()
+			if err != nil {
+				logrus.Warningf("saved search %s is no longer valid: %s", s.ID, err)
+				continue
+			}
+			queried = append(queried, s)
+			service.Add(elastic.NewSearchRequest().Index(index.Claims).SearchSource(elastic.NewSearchSource().
+				Query(elastic.NewBoolQuery().Must(query).Filter(elastic.NewTermsQuery("claimId.keyword", ids...))).
+				FetchSourceContext(elastic.NewFetchSourceContext(true).Include(alertFields...)).
+				Size(maxAlertClaims)))
+		}
+		if len(queried) == 0 {
+			continue
+		}
+		res, err := service.Do(ctx)
+		if err != nil {
+			return errors.Err(err)
+		}
+		for i, result := range res.Responses {
+			if i >= len(queried) || result == nil {
+				continue
+			}
+			if result.Error != nil {
+				logrus.Warningf("saved search %s failed: %s", queried[i].ID, result.Error.Reason)
+				continue
+			}
+			if result.Hits == nil || len(result.Hits.Hits) == 0 {
+				continue
+			}
+			err := alert(ctx, queried[i], result.Hits.Hits)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// alert delivers the hits of the saved search that have not been delivered or are not being delivered already.
+func alert(ctx context.Context, s SavedSearch, hits []*elastic.SearchHit) error {
+	ids := make([]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.Id)
+	}
+	sent, err := delivered(ctx, s.ID, ids)
+	if err != nil {
+		return err
+	}
+	var claims []map[string]interface{}
+	var claimIDs []string
+	for _, hit := range hits {
+		if sent[hit.Id] || hit.Source == nil {
+			continue
+		}
+		claim := map[string]interface{}{}
+		if json.Unmarshal(*hit.Source, &claim) != nil {
+			continue
+		}
+		claims = append(claims, claim)
+		claimIDs = append(claimIDs, hit.Id)
+	}
+	if len(claims) == 0 {
+		return nil
+	}
+	metrics.AlertMatches.Add(float64(len(claims)))
+	// The pending delivery is logged before this returns, so that the next match does not alert on the claims again.
+	now := time.Now().UTC()
+	d := Delivery{
+		ID:        randomHex(16),
+		SearchID:  s.ID,
+		ClaimIDs:  claimIDs,
+		Status:    StatusPending,
+		Attempts:  []Attempt{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = logDelivery(ctx, d)
+	if err != nil {
+		return err
+	}
+	deliver(s, d, claims)
+	return nil
+}
+
+// delivered returns which of the claim ids were delivered, or are being delivered, for the saved search.
+func delivered(ctx context.Context, searchID string, claimIDs []interface{}) (map[string]bool, error) {
+	sent := make(map[string]bool)
+	res, err := es.Client.Search(Deliveries).
+		Query(elastic.NewBoolQuery().Filter(
+			elastic.NewTermQuery("search_id.keyword", searchID),
+			elastic.NewTermsQuery("claim_ids.keyword", claimIDs...),
+			elastic.NewTermsQuery("status.keyword", StatusDelivered, StatusPending))).
+		FetchSourceContext(elastic.NewFetchSourceContext(true).Include("claim_ids")).
+		Size(1000).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return sent, nil
+	}
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	for _, hit := range res.Hits.Hits {
+		var d Delivery
+		if hit.Source == nil || json.Unmarshal(*hit.Source, &d) != nil {
+			continue
+		}
+		for _, id := range d.ClaimIDs {
+			sent[id] = true
+		}
+	}
+	return sent, nil
+}
@@ -2,38 +2,126 @@ package app
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
-	"strings"
+	"syscall"
+	"time"
 
 	"github.com/lbryio/lighthouse/app/internal/metrics"
 
 	"github.com/lbryio/lbry.go/v2/extras/api"
 	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lighthouse/app/accesslog"
 	"github.com/lbryio/lighthouse/app/actions"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
 	"github.com/lbryio/lighthouse/app/es"
-	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/httpcache"
+	"github.com/lbryio/lighthouse/app/ratelimit"
+	"github.com/lbryio/lighthouse/app/rpc"
+	"github.com/lbryio/lighthouse/app/stream"
+	"github.com/lbryio/lighthouse/app/timeout"
+	"github.com/lbryio/lighthouse/app/tracing"
 	"github.com/lbryio/lighthouse/app/util"
 
 	"github.com/fatih/color"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 	"gopkg.in/olivere/elastic.v6"
 )
 
 // InstanceName is used to describe the instance running and that is used in the powered by header
 var InstanceName = "UNKOWN"
 
-//DoYourThing launches the app
-func DoYourThing() {
-	initElasticSearch()
-	initAPIServer()
+// PromUser is the basic auth user allowed to scrape the prometheus metrics
+var PromUser = "prom"
+
+// DefaultPromPassword is the password the metrics were scraped with before it was configurable.
+const DefaultPromPassword = "prom-lighthouse-access"
+
+// PromPassword is the basic auth password allowed to scrape the prometheus metrics. Scraping is refused when unset.
+var PromPassword = DefaultPromPassword
+
+// AdminTLSCert and AdminTLSKey are the certificate and key files of the admin server.
+var AdminTLSCert, AdminTLSKey string
+
+// AdminClientCA is the file of the certificate authorities the client certificates of the admin server are verified
+// against.
+var AdminClientCA string
+
+// shutdownTimeout is used when no deadline for draining in-flight requests is configured.
+const shutdownTimeout = 30 * time.Second
+
+// DoYourThing launches the app and blocks until it receives SIGTERM or SIGINT. It then stops accepting connections
+// and drains in-flight requests, returning an error if they do not finish before the shutdown deadline.
+func DoYourThing() error {
+	InitElasticSearch()
+	server := initAPIServer()
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			logrus.Fatal(err)
+		}
+	}()
+	rpcServer := initRPCServer()
+	adminServer := initAdminServer()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-interrupt
+	signal.Stop(interrupt)
+
+	timeout := viper.GetDuration("shutdowntimeout")
+	if timeout <= 0 {
+		timeout = shutdownTimeout
+	}
+	logrus.Infof("received %s, draining API server for up to %s...", sig, timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if rpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			rpcServer.GracefulStop()
+			close(stopped)
+		}()
+		go func() {
+			<-ctx.Done()
+			rpcServer.Stop()
+		}()
+		defer func() { <-stopped }()
+	}
+	if adminServer != nil {
+		defer func() {
+			if err := adminServer.Shutdown(ctx); err != nil {
+				logrus.Error(errors.Prefix("admin server did not shut down cleanly", err))
+			}
+		}()
+	}
+	err := server.Shutdown(ctx)
+	es.Client.Stop()
+	if err != nil {
+		return errors.Prefix("API server did not shut down cleanly", err)
+	}
+	logrus.Info("API server shut down")
+	return nil
 }
 
-func initElasticSearch() {
-	opts := []elastic.ClientOptionFunc{elastic.SetErrorLog(logrus.StandardLogger())}
+// InitElasticSearch connects the elasticsearch client and makes sure the claims index and its aliases exist.
+func InitElasticSearch() {
+	opts := []elastic.ClientOptionFunc{
+		elastic.SetErrorLog(logrus.StandardLogger()),
+		elastic.SetHttpClient(&http.Client{Transport: tracing.Transport("elasticsearch", http.DefaultTransport)}),
+	}
 	if es.ElasticSearchURL != "" {
 		opts = append(opts, elastic.SetURL(es.ElasticSearchURL))
 	}
@@ -51,19 +139,13 @@ func initElasticSearch() {
 	}
 	client.Start()
 	es.Client = client
-	exists, err := client.IndexExists(index.Claims).Do(context.Background())
+	err = es.EnsureClaimsIndex(context.Background())
 	if err != nil {
 		logrus.Panic(err)
 	}
-	if !exists {
-		_, err := client.CreateIndex(index.Claims).BodyString(index.ClaimMapping).Do(context.Background())
-		if err != nil {
-			logrus.Panic(err)
-		}
-	}
 }
 
-func initAPIServer() {
+func initAPIServer() *http.Server {
 	api.TraceEnabled = util.Debugging
 	host := viper.GetString("host")
 	port := viper.GetInt("port")
@@ -78,7 +160,7 @@ func initAPIServer() {
 	hs["X-Powered-By"] = InstanceName
 	api.ResponseHeaders = hs
 	api.Log = func(request *http.Request, response *api.Response, err error) {
-		consoleText := request.RemoteAddr + " [" + strconv.Itoa(response.Status) + "]: " + request.Method + " " + request.URL.Path
+		consoleText := accesslog.RequestID(request) + " " + request.RemoteAddr + " [" + strconv.Itoa(response.Status) + "]: " + request.Method + " " + request.URL.Path
 		if err == nil {
 			logrus.Debug(color.GreenString(consoleText))
 		} else {
@@ -108,12 +190,78 @@ func initAPIServer() {
 	mux := http.Handler(httpServeMux)
 
 	for _, middleware := range []func(h http.Handler) http.Handler{
-		promRequestHandler,
+		timeout.Handler,
+		breaker.Handler,
+		httpcache.Handler,
+		ratelimit.Handler,
+		auth.Handler,
+		promRequestHandler(httpServeMux),
+		accesslog.Handler,
+		tracing.Handler(httpServeMux),
 	} {
 		mux = middleware(mux)
 	}
 
-	logrus.Fatal(http.ListenAndServe(host+":"+strconv.Itoa(port), mux))
+	server := &http.Server{Addr: host + ":" + strconv.Itoa(port), Handler: mux}
+	// Streams never finish on their own, close them so that shutdown can drain the other requests.
+	server.RegisterOnShutdown(stream.Shutdown)
+	return server
+}
+
+// initAdminServer starts the admin api on its own port, served over TLS to clients presenting a certificate signed
+// by AdminClientCA, unless the port is 0.
+func initAdminServer() *http.Server {
+	port := viper.GetInt("adminport")
+	if port == 0 {
+		return nil
+	}
+	if AdminTLSCert == "" || AdminTLSKey == "" || AdminClientCA == "" {
+		logrus.Fatal("the admin server requires ADMIN_TLS_CERT, ADMIN_TLS_KEY and ADMIN_CLIENT_CA")
+	}
+	ca, err := ioutil.ReadFile(AdminClientCA)
+	if err != nil {
+		logrus.Fatal(errors.Err(err))
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		logrus.Fatalf("no certificates found in %s", AdminClientCA)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", api.Handler(actions.Admin))
+	server := &http.Server{
+		Addr:      viper.GetString("host") + ":" + strconv.Itoa(port),
+		Handler:   accesslog.Handler(mux),
+		TLSConfig: &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool, MinVersion: tls.VersionTLS12},
+	}
+	logrus.Infof("Admin Server started @ %s", "https://"+server.Addr+"/admin/sync")
+	go func() {
+		err := server.ListenAndServeTLS(AdminTLSCert, AdminTLSKey)
+		if err != nil && err != http.ErrServerClosed {
+			logrus.Fatal(err)
+		}
+	}()
+	return server
+}
+
+// initRPCServer starts the grpc server on its own port, unless the port is 0.
+func initRPCServer() *grpc.Server {
+	port := viper.GetInt("grpcport")
+	if port == 0 {
+		return nil
+	}
+	listener, err := net.Listen("tcp", viper.GetString("host")+":"+strconv.Itoa(port))
+	if err != nil {
+		logrus.Fatal(errors.Err(err))
+	}
+	server := rpc.NewServer()
+	logrus.Infof("gRPC Server started @ %s", listener.Addr())
+	go func() {
+		err := server.Serve(listener)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}()
+	return server
 }
 
 func promBasicAuthWrapper(h http.Handler) http.Handler {
@@ -123,7 +271,13 @@ func promBasicAuthWrapper(h http.Handler) http.Handler {
 			http.Error(w, "authentication required", http.StatusBadRequest)
 			return
 		}
-		if user == "prom" && pass == "prom-lighthouse-access" {
+		if PromPassword == "" {
+			http.Error(w, "metrics credentials are not configured", http.StatusForbidden)
+			return
+		}
+		validUser := subtle.ConstantTimeCompare([]byte(user), []byte(PromUser)) == 1
+		validPass := subtle.ConstantTimeCompare([]byte(pass), []byte(PromPassword)) == 1
+		if validUser && validPass {
 			h.ServeHTTP(w, r)
 		} else {
 			http.Error(w, "invalid username or password", http.StatusForbidden)
@@ -133,13 +287,54 @@ func promBasicAuthWrapper(h http.Handler) http.Handler {
 
 const promPath = "/metrics"
 
-func promRequestHandler(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := strings.TrimLeft(r.URL.Path, "/")
-		if path != strings.TrimLeft(promPath, "/") {
-			h.ServeHTTP(w, r)
-		} else {
-			h.ServeHTTP(w, r)
-		}
-	})
+// promRequestHandler records request counts, errors, in-flight requests, latencies and response sizes labeled by the
+// route pattern of the mux that serves the request, so that arbitrary paths do not create new series.
+func promRequestHandler(mux *http.ServeMux) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, route := mux.Handler(r)
+			if route == promPath {
+				h.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			inFlight := metrics.HTTPInFlight.WithLabelValues(route)
+			inFlight.Inc()
+			defer inFlight.Dec()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(rec, r)
+
+			class := strconv.Itoa(rec.status/100) + "xx"
+			metrics.HTTPRequests.WithLabelValues(route, r.Method, class).Inc()
+			if rec.status >= http.StatusBadRequest {
+				metrics.HTTPErrors.WithLabelValues(route, class).Inc()
+			}
+			metrics.HTTPDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+			metrics.HTTPResponseSize.WithLabelValues(route).Observe(float64(rec.size))
+		})
+	}
+}
+
+// statusRecorder captures the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
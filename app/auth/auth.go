@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyHeader is the header used to present an API key.
+const APIKeyHeader = "X-Api-Key"
+
+// Tier holds the permissions and limits of a class of callers.
+type Tier struct {
+	Name string
+	// MaxSize is the largest number of results a caller can request at once.
+	MaxSize int
+	// MaxFrom is the deepest offset a caller can page to.
+	MaxFrom int
+	// Debug allows the debug, explain and source parameters which expose internal ranking details.
+	Debug bool
+}
+
+// Tiers are the permission tiers that API keys can be assigned to.
+var Tiers = map[string]*Tier{
+	"anonymous": {Name: "anonymous", MaxSize: 500, MaxFrom: 2000},
+	"partner":   {Name: "partner", MaxSize: 10000, MaxFrom: 9999},
+	"internal":  {Name: "internal", MaxSize: 10000, MaxFrom: 9999, Debug: true},
+}
+
+// Anonymous is the tier of callers without an API key.
+var Anonymous = Tiers["anonymous"]
+
+// Key is an API key and the tier it grants.
+type Key struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Tier string `json:"tier"`
+}
+
+// Caller is the authenticated caller of a request.
+type Caller struct {
+	// Name identifies the API key used, it is empty for anonymous callers.
+	Name string
+	Tier *Tier
+}
+
+var (
+	mu   sync.RWMutex
+	keys = make(map[string]Key)
+)
+
+// Add registers an API key, replacing any key with the same value.
+func Add(k Key) error {
+	if k.Key == "" {
+		return errors.Err("api key for %s is empty", k.Name)
+	}
+	if _, ok := Tiers[k.Tier]; !ok {
+		return errors.Err("api key %s has unknown tier %s", k.Name, k.Tier)
+	}
+	if k.Name == "" {
+		return errors.Err("api key has no name")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	keys[k.Key] = k
+	return nil
+}
+
+// Remove unregisters an API key.
+func Remove(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(keys, key)
+}
+
+// ParseKeys registers the API keys in the form `name:key:tier,name2:key2:tier2`.
+func ParseKeys(list string) error {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return errors.Err("invalid api key entry %q, expected name:key:tier", entry)
+		}
+		err := Add(Key{Name: parts[0], Key: parts[1], Tier: parts[2]})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadKeys registers the API keys stored as a json array in the file.
+func LoadKeys(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Err(err)
+	}
+	var list []Key
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return errors.Err(err)
+	}
+	for _, k := range list {
+		err := Add(k)
+		if err != nil {
+			return err
+		}
+	}
+	logrus.Infof("loaded %d api keys from %s", len(list), file)
+	return nil
+}
+
+type contextKey struct{}
+
+// FromRequest returns the caller of the request, anonymous if no API key was presented.
+func FromRequest(r *http.Request) Caller {
+	return FromContext(r.Context())
+}
+
+// FromContext returns the caller in the context, anonymous if no API key was presented.
+func FromContext(ctx context.Context) Caller {
+	if caller, ok := ctx.Value(contextKey{}).(Caller); ok {
+		return caller
+	}
+	return Caller{Tier: Anonymous}
+}
+
+// Handler identifies the caller of each request from its API key. Requests presenting an unknown key are rejected.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		caller, ok := Lookup(key)
+		if !ok {
+			for hk, hv := range api.ResponseHeaders {
+				w.Header().Set(hk, hv)
+			}
+			body, err := api.BuildJSONResponse(api.ResponseInfo{Error: util.PtrToString("invalid api key")})
+			if err != nil {
+				logrus.Error(err)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write(body)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(WithCaller(r.Context(), caller)))
+	})
+}
+
+// Lookup returns the caller presenting the API key, and false if the key is unknown.
+func Lookup(key string) (Caller, bool) {
+	mu.RLock()
+	k, ok := keys[key]
+	mu.RUnlock()
+	if !ok {
+		return Caller{}, false
+	}
+	metrics.APIKeyRequests.WithLabelValues(k.Name, k.Tier).Inc()
+	return Caller{Name: k.Name, Tier: Tiers[k.Tier]}, true
+}
+
+// WithCaller returns a context in which the caller is the one FromRequest returns.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, contextKey{}, caller)
+}
+
+// CheckLimits returns a 400 or 403 status error if the request parameters exceed what the caller is allowed.
+func (c Caller) CheckLimits(size, from *int, debug bool) error {
+	if size != nil && *size > c.Tier.MaxSize {
+		return api.StatusError{Status: http.StatusBadRequest,
+			Err: errors.Err("size: must be no greater than %d for %s callers", c.Tier.MaxSize, c.Tier.Name)}
+	}
+	if from != nil && *from > c.Tier.MaxFrom {
+		return api.StatusError{Status: http.StatusBadRequest,
+			Err: errors.Err("from: must be no greater than %d for %s callers", c.Tier.MaxFrom, c.Tier.Name)}
+	}
+	if debug && !c.Tier.Debug {
+		return api.StatusError{Status: http.StatusForbidden,
+			Err: errors.Err("debug, score and source parameters require an api key with debug access")}
+	}
+	return nil
+}
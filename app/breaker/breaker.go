@@ -0,0 +1,282 @@
+package breaker
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrOpen is returned by Allow while the circuit is open.
+var ErrOpen = errors.Base("circuit breaker is open")
+
+// DegradedHeader is set on responses served from expired cache entries while the circuit is open.
+const DegradedHeader = "X-Degraded"
+
+// Fallback serves the last cached result for a request, even if expired, while the circuit is open. When disabled, or
+// when nothing is cached, requests fail fast with 503 instead.
+var Fallback = true
+
+// State of a circuit breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// HalfOpen lets a single probe through to decide whether to close or open again.
+	HalfOpen
+	// Open rejects every call until OpenFor has passed.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// Settings holds the thresholds of a circuit breaker.
+type Settings struct {
+	// Window is the period over which calls are counted before the counts are reset.
+	Window time.Duration
+	// MinCalls is the number of calls within the window below which the circuit never opens.
+	MinCalls int
+	// FailureRate is the share of failed calls within the window that opens the circuit.
+	FailureRate float64
+	// SlowCall is the duration above which a successful call counts as a failure.
+	SlowCall time.Duration
+	// OpenFor is how long the circuit stays open before a probe is let through.
+	OpenFor time.Duration
+}
+
+// DefaultSettings are the settings used unless configured otherwise.
+var DefaultSettings = Settings{
+	Window:      10 * time.Second,
+	MinCalls:    20,
+	FailureRate: 0.5,
+	SlowCall:    2 * time.Second,
+	OpenFor:     30 * time.Second,
+}
+
+// Breaker is a circuit breaker that opens when too many calls to a dependency fail or are slow, so that requests
+// fail fast instead of piling up on it.
+type Breaker struct {
+	name     string
+	settings Settings
+
+	mu          sync.Mutex
+	state       State
+	windowStart time.Time
+	calls       int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+// Status is a snapshot of a circuit breaker.
+type Status struct {
+	Name     string
+	State    string
+	Calls    int
+	Failures int
+	// OpenedAt is the last time the circuit opened.
+	OpenedAt time.Time
+}
+
+// New creates a closed circuit breaker with the name used in metrics.
+func New(name string, settings Settings) *Breaker {
+	metrics.BreakerState.WithLabelValues(name).Set(float64(Closed))
+	return &Breaker{name: name, settings: settings, windowStart: time.Now()}
+}
+
+// Configure replaces the thresholds of the breaker.
+func (b *Breaker) Configure(settings Settings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settings = settings
+}
+
+// Allow returns ErrOpen if the call must not be made. Every allowed call must be followed by a call to Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.settings.OpenFor {
+			return ErrOpen
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record records the outcome of an allowed call. Calls canceled by the caller are not counted.
+func (b *Breaker) Record(duration time.Duration, err error) {
+	if err != nil && stderrors.Is(errors.Unwrap(err), context.Canceled) {
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failed := err != nil || (b.settings.SlowCall > 0 && duration > b.settings.SlowCall)
+	if b.state == HalfOpen {
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.reset()
+			b.setState(Closed)
+		}
+		return
+	}
+	if b.state == Open {
+		return
+	}
+	if time.Since(b.windowStart) > b.settings.Window {
+		b.reset()
+	}
+	b.calls++
+	if failed {
+		b.failures++
+	}
+	if b.calls >= b.settings.MinCalls && float64(b.failures)/float64(b.calls) >= b.settings.FailureRate {
+		logrus.Warningf("%s circuit breaker opened after %d failed or slow calls out of %d", b.name, b.failures, b.calls)
+		b.open()
+	}
+}
+
+// Status returns a snapshot of the breaker.
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.state
+	if state == Open && time.Since(b.openedAt) >= b.settings.OpenFor {
+		state = HalfOpen
+	}
+	return Status{Name: b.name, State: state.String(), Calls: b.calls, Failures: b.failures, OpenedAt: b.openedAt}
+}
+
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.reset()
+	b.setState(Open)
+}
+
+func (b *Breaker) reset() {
+	b.windowStart = time.Now()
+	b.calls = 0
+	b.failures = 0
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	metrics.BreakerState.WithLabelValues(b.name).Set(float64(state))
+	metrics.BreakerTransitions.WithLabelValues(b.name, state.String()).Inc()
+}
+
+// Unavailable returns the 503 status error for requests that cannot be served while the circuit of the dependency
+// is open.
+func Unavailable(dependency string) error {
+	return api.StatusError{Status: http.StatusServiceUnavailable,
+		Err: errors.Err("%s is unavailable, try again later", dependency)}
+}
+
+// Stale returns value, the expired cache entry for the request if ok, marking the response as degraded. It returns
+// the error of Unavailable if there is no such entry or Fallback is disabled.
+func Stale(r *http.Request, dependency, searchType string, value interface{}, ok bool) (interface{}, error) {
+	if !Fallback || !ok {
+		metrics.Degraded.WithLabelValues(searchType, "unavailable").Inc()
+		return nil, Unavailable(dependency)
+	}
+	metrics.Degraded.WithLabelValues(searchType, "stale").Inc()
+	SetDegraded(r)
+	return value, nil
+}
+
+type contextKey struct{}
+
+// SetDegraded marks the response for the request as served from an expired cache entry.
+func SetDegraded(r *http.Request) {
+	if degraded, ok := r.Context().Value(contextKey{}).(*int32); ok {
+		atomic.StoreInt32(degraded, 1)
+	}
+}
+
+// Track returns a context in which SetDegraded records whether the response was served from an expired cache entry,
+// and a function reporting it, for requests that are not served through Handler.
+func Track(ctx context.Context) (context.Context, func() bool) {
+	degraded := new(int32)
+	return context.WithValue(ctx, contextKey{}, degraded), func() bool {
+		return atomic.LoadInt32(degraded) == 1
+	}
+}
+
+// Handler sets the degraded header on responses marked with SetDegraded. They are not cached downstream as they can
+// be refreshed once the circuit closes.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		degraded := new(int32)
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, degraded))
+		h.ServeHTTP(&recorder{ResponseWriter: w, degraded: degraded}, r)
+	})
+}
+
+type recorder struct {
+	http.ResponseWriter
+	degraded *int32
+	wrote    bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.wrote = true
+		if atomic.LoadInt32(r.degraded) == 1 {
+			r.Header().Set(DegradedHeader, "true")
+			r.Header().Set("Cache-Control", "no-store")
+		}
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) Flush() {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,301 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/tracing"
+
+	"github.com/karlseguin/ccache"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Cache is an LRU cache for api responses that coalesces concurrent fetches of the same key and serves stale values
+// while a single background refresh runs.
+type Cache struct {
+	// counters are first to keep them 64-bit aligned for atomic operations
+	hits, misses, stale, coalesced int64
+
+	name       string
+	store      *ccache.Cache
+	ttl        time.Duration
+	staleWhile time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]*call
+}
+
+// Status describes how a value was served by the cache.
+type Status string
+
+const (
+	// Hit is a value served fresh from the cache.
+	Hit Status = "hit"
+	// Miss is a value fetched because it was not cached or expired past the stale window.
+	Miss Status = "miss"
+	// Stale is an expired value served while it is refreshed in the background.
+	Stale Status = "stale"
+	// Coalesced is a value fetched for another request of the same key while this one waited.
+	Coalesced Status = "coalesced"
+)
+
+// Stats holds the number of items in a cache and how its requests were served since startup.
+type Stats struct {
+	Name      string
+	Items     int
+	Hits      int64
+	Misses    int64
+	Stale     int64
+	Coalesced int64
+	// HitRatio is the share of requests served without waiting on a fetch, stale values included.
+	HitRatio float64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Cache
+)
+
+// AllStats returns the stats of every cache created with New.
+func AllStats() []Stats {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	stats := make([]Stats, len(registry))
+	for i, c := range registry {
+		stats[i] = c.Stats()
+	}
+	return stats
+}
+
+// Stats returns the number of items in the cache and how its requests were served since startup.
+func (c *Cache) Stats() Stats {
+	s := Stats{
+		Name:      c.name,
+		Items:     c.store.ItemCount(),
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Stale:     atomic.LoadInt64(&c.stale),
+		Coalesced: atomic.LoadInt64(&c.coalesced),
+	}
+	if total := s.Hits + s.Misses + s.Stale + s.Coalesced; total > 0 {
+		s.HitRatio = float64(s.Hits+s.Stale) / float64(total)
+	}
+	return s
+}
+
+func (c *Cache) count(status Status) {
+	switch status {
+	case Hit:
+		atomic.AddInt64(&c.hits, 1)
+	case Miss:
+		atomic.AddInt64(&c.misses, 1)
+	case Stale:
+		atomic.AddInt64(&c.stale, 1)
+	case Coalesced:
+		atomic.AddInt64(&c.coalesced, 1)
+	}
+}
+
+// call is a fetch in progress that other callers of the same key wait on.
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	// waiters is the number of requests waiting on the fetch, guarded by Cache.mu. The fetch is canceled when the
+	// last of them leaves, unless cancel is nil as for background refreshes.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// noStore is a fetched value that is returned to the callers waiting on it but not cached.
+type noStore struct {
+	value interface{}
+}
+
+// NoStore wraps a value returned by a fetch so that it is not cached, such as partial results.
+func NoStore(value interface{}) interface{} {
+	return noStore{value: value}
+}
+
+// New creates a cache with the given name used for metrics. Values are fresh for ttl and served stale for up to
+// staleWhile past expiry while they are refreshed in the background.
+func New(name string, maxSize int64, ttl, staleWhile time.Duration) *Cache {
+	c := &Cache{
+		name:       name,
+		store:      ccache.New(ccache.Configure().MaxSize(maxSize)),
+		ttl:        ttl,
+		staleWhile: staleWhile,
+		inFlight:   make(map[string]*call),
+	}
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c
+}
+
+// Fetch returns the cached value for the key, calling fetch on a miss. Concurrent misses for the same key share a
+// single call to fetch. If the value expired less than the stale window ago it is returned as is and one refresh is
+// started in the background. Fetch is called with a context that carries the trace and the deadline of ctx but is
+// not canceled with it, as its result can be shared with other requests. It is canceled once every request waiting
+// on it has been canceled.
+func (c *Cache) Fetch(ctx context.Context, key string, fetch func(ctx context.Context) (interface{}, error)) (interface{}, Status, error) {
+	ctx, span := tracing.Start(ctx, "cache.fetch", attribute.String("cache", c.name))
+	value, status, err := c.fetch(ctx, key, fetch)
+	span.SetAttributes(attribute.String("cache.status", string(status)))
+	tracing.End(span, err)
+	metrics.CacheRequests.WithLabelValues(c.name, string(status)).Inc()
+	c.count(status)
+	return value, status, err
+}
+
+// Peek returns the value cached for the key even if it expired past the stale window, as long as it was not evicted.
+func (c *Cache) Peek(key string) (interface{}, bool) {
+	item := c.store.Get(key)
+	if item == nil {
+		return nil, false
+	}
+	return item.Value(), true
+}
+
+// detach returns a context that carries the trace of ctx and expires after the time left until its deadline, if
+// any, but is not canceled with it.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := tracing.Detach(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithTimeout(detached, time.Until(deadline))
+	}
+	return context.WithCancel(detached)
+}
+
+func (c *Cache) fetch(ctx context.Context, key string, fetch func(ctx context.Context) (interface{}, error)) (interface{}, Status, error) {
+	item := c.store.Get(key)
+	if item != nil && !item.Expired() {
+		return item.Value(), Hit, nil
+	}
+	if item != nil && time.Since(item.Expires()) < c.staleWhile {
+		c.refresh(ctx, key, fetch)
+		return item.Value(), Stale, nil
+	}
+	return c.do(ctx, key, fetch)
+}
+
+// refresh starts a background fetch for the key unless one is already running.
+func (c *Cache) refresh(ctx context.Context, key string, fetch func(ctx context.Context) (interface{}, error)) {
+	c.mu.Lock()
+	if _, ok := c.inFlight[key]; ok {
+		c.mu.Unlock()
+		return
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inFlight[key] = cl
+	c.mu.Unlock()
+
+	// The refresh outlives the request that started it.
+	ctx, cancel := detach(ctx)
+	go func() {
+		defer cancel()
+		c.run(ctx, key, cl, fetch)
+		if cl.err != nil {
+			logrus.Errorf("%s cache: background refresh failed: %s", c.name, cl.err)
+		}
+	}()
+}
+
+// do runs fetch for the key, or waits on the fetch already in flight for it. The request stops waiting when ctx is
+// canceled, and the fetch is canceled if no other request waits on it.
+func (c *Cache) do(ctx context.Context, key string, fetch func(ctx context.Context) (interface{}, error)) (interface{}, Status, error) {
+	status := Coalesced
+	c.mu.Lock()
+	cl, ok := c.inFlight[key]
+	if !ok {
+		fctx, cancel := detach(ctx)
+		cl = &call{done: make(chan struct{}), cancel: cancel}
+		c.inFlight[key] = cl
+		status = Miss
+		go func() {
+			defer cancel()
+			c.run(fctx, key, cl, fetch)
+		}()
+	}
+	cl.waiters++
+	c.mu.Unlock()
+	if status == Coalesced {
+		metrics.CacheCoalesced.WithLabelValues(c.name).Inc()
+	}
+
+	select {
+	case <-cl.done:
+		return cl.value, status, cl.err
+	case <-ctx.Done():
+		c.leave(key, cl)
+		return nil, status, ctx.Err()
+	}
+}
+
+// leave stops a request waiting on the fetch, canceling it when it was the last one. A canceled fetch is no longer
+// joined by new requests.
+func (c *Cache) leave(key string, cl *call) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cl.waiters--
+	if cl.waiters > 0 || cl.cancel == nil {
+		return
+	}
+	cl.cancel()
+	if c.inFlight[key] == cl {
+		delete(c.inFlight, key)
+	}
+}
+
+func (c *Cache) run(ctx context.Context, key string, cl *call, fetch func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		c.mu.Lock()
+		if c.inFlight[key] == cl {
+			delete(c.inFlight, key)
+		}
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+	value, err := fetch(ctx)
+	if err != nil {
+		cl.err = err
+		return
+	}
+	if ns, ok := value.(noStore); ok {
+		cl.value = ns.value
+		return
+	}
+	c.store.Set(key, value, c.ttl)
+	cl.value = value
+}
+
+// Key builds a normalized cache key for the request so that equivalent requests share an entry. Parameters are
+// sorted by name, and the values of the folded parameters are trimmed and lower cased. The values of a repeated
+// parameter keep their order, as handlers read the first of them.
+func Key(r *http.Request, folded ...string) string {
+	if r.Form == nil {
+		_ = r.ParseForm()
+	}
+	values := make(url.Values, len(r.Form))
+	for param, vals := range r.Form {
+		normalized := make([]string, len(vals))
+		copy(normalized, vals)
+		for _, f := range folded {
+			if f == param {
+				for i, v := range normalized {
+					normalized[i] = strings.ToLower(strings.TrimSpace(v))
+				}
+			}
+		}
+		values[param] = normalized
+	}
+	// Encode sorts by parameter name
+	return r.URL.Path + "?" + values.Encode()
+}
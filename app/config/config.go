@@ -3,11 +3,25 @@ package config
 import (
 	"github.com/johntdyer/slackrus"
 	"github.com/lbryio/lighthouse/app"
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/actions"
+	"github.com/lbryio/lighthouse/app/actions/search"
+	"github.com/lbryio/lighthouse/app/admin"
+	"github.com/lbryio/lighthouse/app/alerts"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
 	"github.com/lbryio/lighthouse/app/db"
 	"github.com/lbryio/lighthouse/app/env"
 	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/httpcache"
+	"github.com/lbryio/lighthouse/app/jobs/blocked"
 	"github.com/lbryio/lighthouse/app/jobs/chainquery"
 	"github.com/lbryio/lighthouse/app/jobs/internalapis"
+	"github.com/lbryio/lighthouse/app/jobs/source"
+	"github.com/lbryio/lighthouse/app/ratelimit"
+	"github.com/lbryio/lighthouse/app/stream"
+	"github.com/lbryio/lighthouse/app/timeout"
+	"github.com/lbryio/lighthouse/app/tracing"
 	"github.com/lbryio/lighthouse/app/util"
 
 	"github.com/sirupsen/logrus"
@@ -21,13 +35,62 @@ func InitializeConfiguration() {
 		logrus.Panic(err)
 	}
 	InitSlack(config)
-	db.InitChainquery(config.ChainQueryDsn)
 	internalapis.APIToken = config.APIToken
 	internalapis.APIURL = config.APIURL
 	//db.InitInternalAPIs(config.InternalAPIDSN)
 	es.ElasticSearchURL = config.ElasticSearchURL
+	es.IsBlocked = blocked.IsBlocked
 	chainquery.SyncStateDir = config.SyncStateDir
+	InitClaimSource(config)
 	app.InstanceName = config.SlackID
+	app.PromUser = config.PromUser
+	app.PromPassword = config.PromPassword
+	if app.PromPassword == app.DefaultPromPassword {
+		logrus.Warn("the metrics are scraped with the default password, set PROM_PASSWORD")
+	}
+	httpcache.ParseCacheControl(config.CacheControl)
+	if config.CDNPurgeURL != "" {
+		httpcache.Purge = httpcache.NewPurger(config.CDNPurgeURL, config.CDNPurgeToken)
+	}
+	actions.MaxSyncLag = config.MaxSyncLag
+	accesslog.Enabled = config.AccessLog
+	timeout.Default = config.DefaultTimeout
+	timeout.ParseRoutes(config.Timeouts)
+	search.WebURL = config.WebURL
+	search.MaxExport = config.MaxExport
+	alerts.MaxAttempts = config.AlertsMaxAttempts
+	alerts.RetryBackoff = config.AlertsRetryBackoff
+	alerts.AllowPrivateWebhooks = config.AlertsPrivate
+	stream.BufferSize = config.StreamBuffer
+	stream.MaxPending = config.StreamMaxPending
+	stream.MaxSubscribers = config.StreamMaxClients
+	stream.MaxPerClient = config.StreamMaxPerClient
+	actions.StreamHeartbeat = config.StreamHeartbeat
+	chainquery.OnIndexed = func(claimIDs []string) {
+		stream.Publish(claimIDs)
+		alerts.Match(claimIDs)
+	}
+	breaker.Fallback = config.BreakerFallback
+	es.Breaker.Configure(breaker.Settings{
+		Window:      config.BreakerWindow,
+		MinCalls:    config.BreakerMinCalls,
+		FailureRate: config.BreakerFailureRate,
+		SlowCall:    config.BreakerSlowCall,
+		OpenFor:     config.BreakerOpenFor,
+	})
+	InitRateLimit(config)
+	InitAPIKeys(config)
+	err = admin.ParseTokens(config.AdminTokens)
+	if err != nil {
+		logrus.Panic(err)
+	}
+	app.AdminTLSCert = config.AdminTLSCert
+	app.AdminTLSKey = config.AdminTLSKey
+	app.AdminClientCA = config.AdminClientCA
+	err = tracing.Init(config.TracingExporter, config.TracingEndpoint, config.TracingSampling)
+	if err != nil {
+		logrus.Panic(err)
+	}
 	if viper.GetBool("debugmode") {
 		util.Debugging = true
 		logrus.SetLevel(logrus.DebugLevel)
@@ -39,6 +102,67 @@ func InitializeConfiguration() {
 
 }
 
+// InitClaimSource sets the source of the claims synced: chainquery, a file of newline-delimited JSON claims or the
+// claim_search of a JSON-RPC API. Chainquery is connected to if configured, other sources only use it to look up the
+// claims of the blocked lists.
+func InitClaimSource(config *env.Config) {
+	if config.ChainQueryDsn != "" {
+		db.InitChainquery(config.ChainQueryDsn)
+	}
+	switch config.ClaimSource {
+	case "", "chainquery":
+		chainquery.Source = chainquery.Chainquery{}
+	case "file":
+		if config.ClaimSourceFile == "" {
+			logrus.Panic("CLAIM_SOURCE_FILE must be set for the file claim source")
+		}
+		chainquery.Source = source.NewFile(config.ClaimSourceFile)
+	case "rpc":
+		if config.ClaimSourceURL == "" {
+			logrus.Panic("CLAIM_SOURCE_URL must be set for the rpc claim source")
+		}
+		chainquery.Source = source.NewRPC(config.ClaimSourceURL)
+	default:
+		logrus.Panicf("unknown claim source %q, expected chainquery, file or rpc", config.ClaimSource)
+	}
+}
+
+// InitRateLimit configures the rate limits of the API server and whether they are shared across replicas.
+func InitRateLimit(config *env.Config) {
+	ratelimit.Enabled = config.RateLimit
+	// The time until a bucket refills is divided by its rate.
+	if config.RateLimitIPRate <= 0 || config.RateLimitKeyRate <= 0 {
+		logrus.Panic("RATE_LIMIT_IP_RATE and RATE_LIMIT_KEY_RATE must be greater than 0")
+	}
+	ratelimit.IPLimit = ratelimit.Limit{Rate: config.RateLimitIPRate, Burst: config.RateLimitIPBurst}
+	ratelimit.KeyLimit = ratelimit.Limit{Rate: config.RateLimitKeyRate, Burst: config.RateLimitKeyBurst}
+	ratelimit.TrustProxy = config.TrustProxy
+	ratelimit.ParseCosts(config.RateLimitCosts)
+	if config.RateLimitRedis != "" {
+		err := ratelimit.UseRedis(config.RateLimitRedis)
+		if err != nil {
+			logrus.Panic(err)
+		}
+	}
+}
+
+// InitAPIKeys sets the limits of anonymous callers and registers the API keys from the environment and the keys
+// file, if any.
+func InitAPIKeys(config *env.Config) {
+	auth.Anonymous.MaxSize = config.AnonymousMaxSize
+	auth.Anonymous.MaxFrom = config.AnonymousMaxFrom
+	err := auth.ParseKeys(config.APIKeys)
+	if err != nil {
+		logrus.Panic(err)
+	}
+	if config.APIKeysFile != "" {
+		err := auth.LoadKeys(config.APIKeysFile)
+		if err != nil {
+			logrus.Panic(err)
+		}
+	}
+}
+
 // InitSlack initializes the slack connection and posts info level or greater to the set channel.
 func InitSlack(config *env.Config) {
 	slackURL := config.SlackHookURL
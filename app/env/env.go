@@ -1,6 +1,8 @@
 package env
 
 import (
+	"time"
+
 	"github.com/lbryio/lbry.go/extras/errors"
 
 	e "github.com/caarlos0/env"
@@ -8,15 +10,62 @@ import (
 
 // Config holds the environment configuration used by lighthouse.
 type Config struct {
-	ChainQueryDsn    string `env:"CHAINQUERY_DSN"`
-	SyncStateDir     string `env:"SYNCSTATEDIR"`
-	ElasticSearchURL string `env:"ELASTICSEARCHURL"`
-	InternalAPIDSN   string `env:"INTERNALAPIS_DSN"`
-	APIURL           string `env:"API_URL"`
-	APIToken         string `env:"API_TOKEN"`
-	SlackHookURL     string `env:"SLACKHOOKURL"`
-	SlackChannel     string `env:"SLACKCHANNEL"`
-	SlackID          string `env:"SLACK_ID"`
+	ChainQueryDsn      string        `env:"CHAINQUERY_DSN"`
+	SyncStateDir       string        `env:"SYNCSTATEDIR"`
+	ElasticSearchURL   string        `env:"ELASTICSEARCHURL"`
+	InternalAPIDSN     string        `env:"INTERNALAPIS_DSN"`
+	APIURL             string        `env:"API_URL"`
+	APIToken           string        `env:"API_TOKEN"`
+	SlackHookURL       string        `env:"SLACKHOOKURL"`
+	SlackChannel       string        `env:"SLACKCHANNEL"`
+	SlackID            string        `env:"SLACK_ID"`
+	CacheControl       string        `env:"CACHE_CONTROL"`
+	CDNPurgeURL        string        `env:"CDN_PURGE_URL"`
+	CDNPurgeToken      string        `env:"CDN_PURGE_TOKEN"`
+	RateLimit          bool          `env:"RATE_LIMIT"`
+	RateLimitIPRate    float64       `env:"RATE_LIMIT_IP_RATE" envDefault:"10"`
+	RateLimitIPBurst   float64       `env:"RATE_LIMIT_IP_BURST" envDefault:"50"`
+	RateLimitKeyRate   float64       `env:"RATE_LIMIT_KEY_RATE" envDefault:"50"`
+	RateLimitKeyBurst  float64       `env:"RATE_LIMIT_KEY_BURST" envDefault:"250"`
+	RateLimitCosts     string        `env:"RATE_LIMIT_COSTS"`
+	RateLimitRedis     string        `env:"RATE_LIMIT_REDIS"`
+	TrustProxy         bool          `env:"TRUST_PROXY"`
+	APIKeys            string        `env:"API_KEYS"`
+	APIKeysFile        string        `env:"API_KEYS_FILE"`
+	AnonymousMaxSize   int           `env:"ANONYMOUS_MAX_SIZE" envDefault:"500"`
+	AnonymousMaxFrom   int           `env:"ANONYMOUS_MAX_FROM" envDefault:"2000"`
+	AccessLog          bool          `env:"ACCESS_LOG" envDefault:"true"`
+	PromUser           string        `env:"PROM_USER" envDefault:"prom"`
+	PromPassword       string        `env:"PROM_PASSWORD" envDefault:"prom-lighthouse-access"`
+	TracingExporter    string        `env:"TRACING_EXPORTER"`
+	TracingEndpoint    string        `env:"TRACING_ENDPOINT"`
+	TracingSampling    float64       `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+	MaxSyncLag         time.Duration `env:"MAX_SYNC_LAG" envDefault:"1h"`
+	Timeouts           string        `env:"TIMEOUTS"`
+	DefaultTimeout     time.Duration `env:"DEFAULT_TIMEOUT" envDefault:"10s"`
+	BreakerWindow      time.Duration `env:"BREAKER_WINDOW" envDefault:"10s"`
+	BreakerMinCalls    int           `env:"BREAKER_MIN_CALLS" envDefault:"20"`
+	BreakerFailureRate float64       `env:"BREAKER_FAILURE_RATE" envDefault:"0.5"`
+	BreakerSlowCall    time.Duration `env:"BREAKER_SLOW_CALL" envDefault:"2s"`
+	BreakerOpenFor     time.Duration `env:"BREAKER_OPEN_FOR" envDefault:"30s"`
+	BreakerFallback    bool          `env:"BREAKER_FALLBACK" envDefault:"true"`
+	WebURL             string        `env:"WEB_URL" envDefault:"https://odysee.com"`
+	MaxExport          int           `env:"MAX_EXPORT" envDefault:"100000"`
+	AlertsMaxAttempts  int           `env:"ALERTS_MAX_ATTEMPTS" envDefault:"5"`
+	AlertsRetryBackoff time.Duration `env:"ALERTS_RETRY_BACKOFF" envDefault:"10s"`
+	AlertsPrivate      bool          `env:"ALERTS_ALLOW_PRIVATE_WEBHOOKS"`
+	StreamBuffer       int           `env:"STREAM_BUFFER" envDefault:"10000"`
+	StreamMaxPending   int           `env:"STREAM_MAX_PENDING" envDefault:"1000"`
+	StreamMaxClients   int           `env:"STREAM_MAX_CLIENTS" envDefault:"1000"`
+	StreamMaxPerClient int           `env:"STREAM_MAX_PER_CLIENT" envDefault:"10"`
+	StreamHeartbeat    time.Duration `env:"STREAM_HEARTBEAT" envDefault:"15s"`
+	ClaimSource        string        `env:"CLAIM_SOURCE" envDefault:"chainquery"`
+	ClaimSourceFile    string        `env:"CLAIM_SOURCE_FILE"`
+	ClaimSourceURL     string        `env:"CLAIM_SOURCE_URL"`
+	AdminTokens        string        `env:"ADMIN_TOKENS"`
+	AdminTLSCert       string        `env:"ADMIN_TLS_CERT"`
+	AdminTLSKey        string        `env:"ADMIN_TLS_KEY"`
+	AdminClientCA      string        `env:"ADMIN_CLIENT_CA"`
 }
 
 // NewWithEnvVars creates an Config from environment variables
@@ -27,8 +76,8 @@ func NewWithEnvVars() (*Config, error) {
 		return nil, errors.Err(err)
 	}
 
-	if cfg.ChainQueryDsn == "" {
-		return nil, errors.Err("CHAINQUERY_DSN env var required")
+	if cfg.ChainQueryDsn == "" && (cfg.ClaimSource == "" || cfg.ClaimSource == "chainquery") {
+		return nil, errors.Err("CHAINQUERY_DSN env var required to sync claims from chainquery")
 	}
 
 	return cfg, nil
@@ -0,0 +1,104 @@
+package es
+
+import (
+	"context"
+	"sort"
+
+	"github.com/lbryio/lighthouse/app/es/index"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+// EnsureClaimsIndex creates the first version of the claims index behind its read and write aliases if there is no
+// claims index. A claims index created before the aliases is given the write alias, it is replaced by a versioned
+// index with `lighthouse reindex`.
+func EnsureClaimsIndex(ctx context.Context) error {
+	exists, err := Client.IndexExists(index.Claims).Do(ctx)
+	if err != nil {
+		return errors.Err(err)
+	}
+	if !exists {
+		name := index.Name(1)
+		err = CreateClaimsIndex(ctx, name)
+		if err != nil {
+			return err
+		}
+		return MoveAliases(ctx, "", name, index.Claims, index.ClaimsWrite)
+	}
+	read, write, err := ClaimsAliases(ctx)
+	if err != nil {
+		return err
+	}
+	if write == "" {
+		logrus.Warnf("adding the %s alias to %s, run lighthouse reindex to move to a versioned index", index.ClaimsWrite, read)
+		return MoveAliases(ctx, "", read, index.ClaimsWrite)
+	}
+	return nil
+}
+
+// CreateClaimsIndex creates an index with the claims mapping, without aliases.
+func CreateClaimsIndex(ctx context.Context, name string) error {
+	_, err := Client.CreateIndex(name).BodyString(index.ClaimMapping).Do(ctx)
+	if err != nil {
+		return errors.Prefix("creating "+name, err)
+	}
+	return nil
+}
+
+// ClaimsAliases returns the indices the read and write aliases of the claims point to, empty if an alias does not
+// exist. The read index is the legacy index named after the alias if it was created before the aliases.
+func ClaimsAliases(ctx context.Context) (read, write string, err error) {
+	res, err := Client.Aliases().Index("_all").Do(ctx)
+	if err != nil {
+		return "", "", errors.Err(err)
+	}
+	if _, ok := res.Indices[index.Claims]; ok {
+		read = index.Claims
+	}
+	if indices := res.IndicesByAlias(index.Claims); len(indices) > 0 {
+		read = indices[0]
+	}
+	if indices := res.IndicesByAlias(index.ClaimsWrite); len(indices) > 0 {
+		write = indices[0]
+	}
+	return read, write, nil
+}
+
+// ClaimsVersions returns the versions of the claims index that exist, in ascending order.
+func ClaimsVersions(ctx context.Context) ([]int, error) {
+	names, err := Client.IndexNames()
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	var versions []int
+	for _, name := range names {
+		if version, ok := index.Version(name); ok {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// MoveAliases moves the aliases from one index to another in a single atomic update, adding them if from is empty.
+// If from is the legacy index named after the read alias it is deleted in the same update, as the alias cannot be
+// created while it exists.
+func MoveAliases(ctx context.Context, from, to string, aliases ...string) error {
+	service := Client.Alias()
+	for _, alias := range aliases {
+		if from == index.Claims && alias == index.Claims {
+			service.Action(elastic.NewAliasRemoveIndexAction(from))
+		} else if from != "" {
+			service.Remove(from, alias)
+		}
+		service.Add(to, alias)
+	}
+	_, err := service.Do(ctx)
+	if err != nil {
+		return errors.Prefix("moving the aliases to "+to, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package es
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/olivere/elastic.v6"
+)
+
+var (
+	bulkMu         sync.Mutex
+	bulkProcessors = make(map[string]*bulkProcessor)
+)
+
+type bulkProcessor struct {
+	p       *elastic.BulkProcessor
+	started time.Time
+}
+
+// BulkStats holds the stats of the last bulk processor started with a name.
+type BulkStats struct {
+	Name    string
+	Started time.Time
+	elastic.BulkProcessorStats
+}
+
+// NewBulkProcessor starts a bulk processor that reports failures with AfterBulkSend and collects stats, which are
+// available from GetBulkStats until another processor is started with the same name.
+func NewBulkProcessor(ctx context.Context, name string, workers int) (*elastic.BulkProcessor, error) {
+	p, err := Client.BulkProcessor().Name(name).After(AfterBulkSend).Workers(workers).Stats(true).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bulkMu.Lock()
+	bulkProcessors[name] = &bulkProcessor{p: p, started: time.Now()}
+	bulkMu.Unlock()
+	return p, nil
+}
+
+// GetBulkStats returns the stats of the last bulk processor started with each name.
+func GetBulkStats() []BulkStats {
+	bulkMu.Lock()
+	defer bulkMu.Unlock()
+	stats := make([]BulkStats, 0, len(bulkProcessors))
+	for name, b := range bulkProcessors {
+		stats = append(stats, BulkStats{Name: name, Started: b.started, BulkProcessorStats: b.p.Stats()})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -1,10 +1,15 @@
 package es
 
 import (
+	"github.com/lbryio/lighthouse/app/breaker"
+
 	"github.com/sirupsen/logrus"
 	"gopkg.in/olivere/elastic.v6"
 )
 
+// Breaker guards the searches made to serve requests so they fail fast while elasticsearch is degraded.
+var Breaker = breaker.New("elasticsearch", breaker.DefaultSettings)
+
 // Client is the elasticsearch client created on lighthouse startup and is used to make queries to the db.
 var Client *elastic.Client
 
@@ -20,3 +25,9 @@ func AfterBulkSend(executionID int64, requests []elastic.BulkableRequest, respon
 		}
 	}
 }
+
+// Partial reports whether the search did not complete on every shard, and whether that is because it timed out.
+func Partial(result *elastic.SearchResult) (partial bool, timedOut bool) {
+	failed := result.Shards != nil && result.Shards.Failed > 0
+	return result.TimedOut || failed, result.TimedOut
+}
@@ -1,11 +1,21 @@
 package index
 
+import (
+	"regexp"
+	"strconv"
+)
+
 const (
-	// Claims is the name used for the claims index of elastic search
+	// Claims is the name used for the claims index of elastic search. It is the read alias of the versioned index
+	// serving the claims, see Version.
 	Claims = "claims"
+	// ClaimsWrite is the write alias of the claims index. It moves to a new index before Claims does while the index
+	// is rebuilt, so that the new index is complete when searches move to it.
+	ClaimsWrite = "claims_write"
 	// ClaimType is the name used for the type of documents stored in the claims index
 	ClaimType = "claim"
 	// ClaimMapping is the mapping used by lighthouse and is initialized if the claims index does not exist on startup.
+	// Changing it requires building a new index with `lighthouse reindex`.
 	ClaimMapping = `
 {
   "settings": {
@@ -31,3 +41,20 @@ const (
   }
 }`
 )
+
+var versioned = regexp.MustCompile(`^` + Claims + `_v(\d+)$`)
+
+// Name returns the name of the version of the claims index.
+func Name(version int) string {
+	return Claims + "_v" + strconv.Itoa(version)
+}
+
+// Version returns the version of the claims index with the name, and false if it is not a claims index version.
+func Version(name string) (int, bool) {
+	m := versioned.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+	version, err := strconv.Atoi(m[1])
+	return version, err == nil
+}
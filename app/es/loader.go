@@ -0,0 +1,107 @@
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"gopkg.in/olivere/elastic.v6"
+)
+
+// IsBlocked returns whether any of the claim ids, of a claim and of its channel, is on the blocked or filtered lists.
+// Claims it reports are loaded as if they did not exist, so that every lookup hides them as /claim does.
+var IsBlocked func(claimIDs ...string) bool
+
+// Loader batches the lookups of claims by claim id made while serving a request into a single multi get, so that
+// resolving the channel of every result of a search does not query elasticsearch once per result. A Loader is not
+// meant to outlive the request it was created for as claims are never reloaded.
+type Loader struct {
+	ctx context.Context
+
+	mu      sync.Mutex
+	pending []string
+	queued  map[string]bool
+	claims  map[string]map[string]interface{}
+	errs    map[string]error
+}
+
+// NewLoader creates a loader that fetches claims with the deadline and trace of ctx.
+func NewLoader(ctx context.Context) *Loader {
+	return &Loader{
+		ctx:    ctx,
+		queued: make(map[string]bool),
+		claims: make(map[string]map[string]interface{}),
+		errs:   make(map[string]error),
+	}
+}
+
+// Load queues the claim for the next batch and returns a function that returns its source, nil if it does not
+// exist or is blocked. The batch of every claim queued so far is fetched when the first of these functions is called.
+func (l *Loader) Load(claimID string) func() (map[string]interface{}, error) {
+	l.mu.Lock()
+	if !l.queued[claimID] {
+		l.queued[claimID] = true
+		l.pending = append(l.pending, claimID)
+	}
+	l.mu.Unlock()
+	return func() (map[string]interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			l.fetch(l.pending)
+			l.pending = nil
+		}
+		return l.claims[claimID], l.errs[claimID]
+	}
+}
+
+// fetch gets the claims in a single multi get, it is called with the lock held.
+func (l *Loader) fetch(claimIDs []string) {
+	service := Client.MultiGet()
+	source := elastic.NewFetchSourceContext(true).Exclude("value")
+	for _, id := range claimIDs {
+		service.Add(elastic.NewMultiGetItem().Index(index.Claims).Type(index.ClaimType).Id(id).FetchSource(source))
+	}
+	metrics.ESBatchSize.WithLabelValues("claims").Observe(float64(len(claimIDs)))
+	err := Breaker.Allow()
+	if err != nil {
+		l.fail(claimIDs, err)
+		return
+	}
+	start := time.Now()
+	result, err := service.Do(l.ctx)
+	Breaker.Record(time.Since(start), err)
+	metrics.ESDuration.WithLabelValues("mget").Observe(time.Since(start).Seconds())
+	if err != nil {
+		l.fail(claimIDs, errors.Err(err))
+		return
+	}
+	for _, doc := range result.Docs {
+		if !doc.Found || doc.Source == nil {
+			continue
+		}
+		claim := make(map[string]interface{})
+		err := json.Unmarshal(*doc.Source, &claim)
+		if err != nil {
+			l.errs[doc.Id] = errors.Err(err)
+			continue
+		}
+		channelID, _ := claim["channel_claim_id"].(string)
+		if IsBlocked != nil && IsBlocked(doc.Id, channelID) {
+			continue
+		}
+		l.claims[doc.Id] = claim
+	}
+}
+
+func (l *Loader) fail(claimIDs []string, err error) {
+	for _, id := range claimIDs {
+		l.errs[id] = err
+	}
+}
@@ -0,0 +1,196 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// CacheControl holds the Cache-Control header value returned for successful responses by route. Routes without an
+// entry are not cacheable. Routes ending in a slash apply to every path below them.
+var CacheControl = map[string]string{
+	"/search":       "public, max-age=300, stale-while-revalidate=600",
+	"/autocomplete": "public, max-age=300, stale-while-revalidate=600",
+	"/claim/":       "public, max-age=300, stale-while-revalidate=600",
+	"/claims":       "public, max-age=300, stale-while-revalidate=600",
+	"/channel/":     "public, max-age=300, stale-while-revalidate=600",
+	"/search.rss":   "public, max-age=900",
+	"/search.atom":  "public, max-age=900",
+	"/status":       "no-cache",
+	"/openapi.json": "public, max-age=3600",
+	"/admin/":       "no-store",
+}
+
+// Vary is the Vary header value returned with every response. Responses depend on the API key as it unlocks higher
+// limits and debug parameters.
+var Vary = "Accept-Encoding, X-Api-Key"
+
+// ParseCacheControl parses a list of route policies in the form `/route=value;/route2=value2` and applies them over
+// the defaults.
+func ParseCacheControl(policies string) {
+	for _, policy := range strings.Split(policies, ";") {
+		parts := strings.SplitN(policy, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			continue
+		}
+		CacheControl[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+}
+
+type contextKey struct{}
+
+// MaxClaimKeys is the largest number of claim keys in the Surrogate-Key header of a response, so that it stays
+// below the header size limits of CDNs. Responses with more claims are tagged with OverflowKey instead.
+var MaxClaimKeys = 100
+
+// OverflowKey is the surrogate key of the responses containing more than MaxClaimKeys claims. It is purged along with
+// the keys of any claim.
+const OverflowKey = "claims-overflow"
+
+type surrogateKeys struct {
+	mu       sync.Mutex
+	keys     []string
+	claims   int
+	overflow bool
+}
+
+// ClaimKey is the surrogate key used for responses that contain the claim. Purging it from a CDN invalidates every
+// cached response the claim appears in.
+func ClaimKey(claimID string) string {
+	return "claim-" + claimID
+}
+
+// AddClaims adds the surrogate keys of the claims to the response for the request.
+func AddClaims(r *http.Request, claimIDs ...string) {
+	keys, ok := r.Context().Value(contextKey{}).(*surrogateKeys)
+	if !ok {
+		return
+	}
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	for _, id := range claimIDs {
+		if id == "" || keys.overflow {
+			continue
+		}
+		if keys.claims == MaxClaimKeys {
+			keys.overflow = true
+			keys.keys = append(keys.keys[:len(keys.keys)-keys.claims], OverflowKey)
+			continue
+		}
+		keys.keys = append(keys.keys, ClaimKey(id))
+		keys.claims++
+	}
+}
+
+// Handler adds ETag, Cache-Control, Vary and Surrogate-Key headers to GET responses, and answers with
+// 304 Not Modified when the ETag matches the If-None-Match header of the request. Responses the handler marked with
+// Cache-Control: no-store are passed through as is, and flushed responses are streamed without being cached.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+		route := strings.TrimRight(r.URL.Path, "/")
+		keys := &surrogateKeys{keys: []string{strings.TrimLeft(route, "/")}}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, keys))
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		if rec.streaming {
+			return
+		}
+
+		header := w.Header()
+		header.Set("Vary", Vary)
+		if rec.status != http.StatusOK || header.Get("Cache-Control") == "no-store" {
+			header.Set("Cache-Control", "no-store")
+			w.WriteHeader(rec.status)
+			_, _ = w.Write(rec.body.Bytes())
+			return
+		}
+		sum := sha1.Sum(rec.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		header.Set("ETag", etag)
+		if cacheControl, ok := policy(route); ok {
+			header.Set("Cache-Control", cacheControl)
+			keys.mu.Lock()
+			header.Set("Surrogate-Key", strings.Join(keys.keys, " "))
+			keys.mu.Unlock()
+		} else {
+			header.Set("Cache-Control", "no-cache")
+		}
+		if matches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(rec.status)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(rec.body.Bytes())
+		}
+	})
+}
+
+// policy returns the Cache-Control value of the route, or of the longest route ending in a slash that it is below.
+func policy(route string) (string, bool) {
+	if cacheControl, ok := CacheControl[route]; ok {
+		return cacheControl, true
+	}
+	var cacheControl, prefix string
+	for key, value := range CacheControl {
+		if strings.HasSuffix(key, "/") && strings.HasPrefix(route, key) && len(key) > len(prefix) {
+			cacheControl, prefix = value, key
+		}
+	}
+	return cacheControl, prefix != ""
+}
+
+func matches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder buffers the response so its ETag can be computed before anything is written, until it is flushed.
+type recorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.streaming {
+		return
+	}
+	r.status = status
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.streaming {
+		return r.ResponseWriter.Write(b)
+	}
+	return r.body.Write(b)
+}
+
+// Flush writes what was buffered and passes the rest of the response through.
+func (r *recorder) Flush() {
+	if !r.streaming {
+		r.streaming = true
+		r.Header().Set("Vary", Vary)
+		r.Header().Set("Cache-Control", "no-store")
+		r.ResponseWriter.WriteHeader(r.status)
+		_, _ = r.ResponseWriter.Write(r.body.Bytes())
+		r.body.Reset()
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
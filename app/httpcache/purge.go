@@ -0,0 +1,64 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Purge invalidates the cached responses tagged with any of the surrogate keys, it is not set unless a CDN is
+// configured.
+var Purge func(keys []string) error
+
+var purgeClient = &http.Client{Timeout: 10 * time.Second}
+
+// PurgeClaims invalidates the cached responses containing any of the claims, which were changed or removed from the
+// index, along with the responses tagged with OverflowKey.
+func PurgeClaims(claimIDs ...string) {
+	if Purge == nil || len(claimIDs) == 0 {
+		return
+	}
+	for start := 0; start < len(claimIDs); start += MaxClaimKeys {
+		end := start + MaxClaimKeys
+		if end > len(claimIDs) {
+			end = len(claimIDs)
+		}
+		keys := make([]string, 0, end-start+1)
+		for _, id := range claimIDs[start:end] {
+			keys = append(keys, ClaimKey(id))
+		}
+		err := Purge(append(keys, OverflowKey))
+		if err != nil {
+			logrus.Error(errors.Prefix("purging cached responses", err))
+			return
+		}
+	}
+}
+
+// NewPurger returns a Purge that posts the keys to the purge url of a CDN in the Surrogate-Key header, presenting
+// the token as a bearer token if it is not empty.
+func NewPurger(url, token string) func(keys []string) error {
+	return func(keys []string) error {
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		if err != nil {
+			return errors.Err(err)
+		}
+		req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := purgeClient.Do(req)
+		if err != nil {
+			return errors.Err(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return errors.Err("the purge url returned %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
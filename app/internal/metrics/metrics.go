@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -32,6 +33,236 @@ var (
 		Help:      "The duration for auto_complete by type and term count",
 	})
 
+	// ZeroResults metric to capture the queries that returned no results
+	ZeroResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "search",
+		Name:      "zero_results",
+		Help:      "The number of queries returning no results by type and term count",
+	}, []string{"type", "term_count"})
+
+	// ReturnedHits metric to capture the number of results returned per query
+	ReturnedHits = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "search",
+		Name:      "returned_hits",
+		Help:      "The number of results returned per query by type",
+		Buckets:   []float64{0, 1, 5, 10, 20, 50, 100, 500, 1000, 10000},
+	}, []string{"type"})
+
+	// TotalHits metric to capture the number of documents matching each query in elasticsearch
+	TotalHits = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "search",
+		Name:      "total_hits",
+		Help:      "The number of documents matching the query in elasticsearch by type",
+		Buckets:   prometheus.ExponentialBuckets(1, 10, 8),
+	}, []string{"type"})
+
+	// ESTook metric to capture the time elasticsearch reports spending on a query
+	ESTook = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "elasticsearch",
+		Name:      "took",
+		Help:      "The time in seconds elasticsearch reports spending on the query by type",
+	}, []string{"type"})
+
+	// ESDuration metric to capture the wall-clock time of a query including transport and decoding
+	ESDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "elasticsearch",
+		Name:      "duration",
+		Help:      "The wall-clock time in seconds of the query by type",
+	}, []string{"type"})
+
+	// ESIncomplete metric to capture searches that timed out or failed on some shards
+	ESIncomplete = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "elasticsearch",
+		Name:      "incomplete",
+		Help:      "The number of queries that returned partial results or timed out by type and reason",
+	}, []string{"type", "reason"})
+
+	// ESBatchSize metric to capture how many documents are fetched together by each multi get
+	ESBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "elasticsearch",
+		Name:      "batch_size",
+		Help:      "The number of documents fetched by each multi get by type",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200},
+	}, []string{"type"})
+
+	// BreakerState metric to capture the state of the circuit breakers, 0 closed, 1 half open and 2 open
+	BreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "lighthouse",
+		Subsystem: "breaker",
+		Name:      "state",
+		Help:      "The state of the circuit breaker by name, 0 closed, 1 half open and 2 open",
+	}, []string{"name"})
+
+	// BreakerTransitions metric to capture how often the circuit breakers change state
+	BreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "breaker",
+		Name:      "transitions",
+		Help:      "The number of state changes of the circuit breaker by name and new state",
+	}, []string{"name", "state"})
+
+	// Degraded metric to capture the requests served while elasticsearch is unavailable
+	Degraded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "api",
+		Name:      "degraded",
+		Help:      "The number of requests served from expired cache entries or rejected while the circuit breaker is open by type and outcome",
+	}, []string{"type", "outcome"})
+
+	// FilterUsage metric to capture how often each search filter is used
+	FilterUsage = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "search",
+		Name:      "filter_usage",
+		Help:      "The number of queries using each filter",
+	}, []string{"filter"})
+
+	// CacheRequests metric to capture how the caches serve requests
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "cache",
+		Name:      "requests",
+		Help:      "The number of cache lookups by cache and status (hit, miss, stale, coalesced)",
+	}, []string{"cache", "status"})
+
+	// HTTPRequests metric to capture the requests served by route, method and status class
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "http",
+		Name:      "requests",
+		Help:      "The number of requests by route, method and status class",
+	}, []string{"route", "method", "status_class"})
+
+	// HTTPErrors metric to capture the requests answered with a 4xx or 5xx status
+	HTTPErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "http",
+		Name:      "errors",
+		Help:      "The number of error responses by route and status class",
+	}, []string{"route", "status_class"})
+
+	// HTTPInFlight metric to capture the requests currently being served
+	HTTPInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "lighthouse",
+		Subsystem: "http",
+		Name:      "in_flight",
+		Help:      "The number of requests currently being served by route",
+	}, []string{"route"})
+
+	// HTTPDuration metric to capture the latency of requests
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "http",
+		Name:      "duration",
+		Help:      "The duration of requests by route",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"route"})
+
+	// GRPCRequests metric to capture the calls to the grpc service
+	GRPCRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "grpc",
+		Name:      "requests",
+		Help:      "The number of grpc calls by method and status code",
+	}, []string{"method", "code"})
+
+	// GRPCDuration metric to capture the latency of the calls to the grpc service
+	GRPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "grpc",
+		Name:      "duration",
+		Help:      "The duration of grpc calls by method",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"method"})
+
+	// HTTPResponseSize metric to capture the size of responses
+	HTTPResponseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "lighthouse",
+		Subsystem: "http",
+		Name:      "response_size",
+		Help:      "The size in bytes of responses by route",
+		Buckets:   prometheus.ExponentialBuckets(100, 4, 9),
+	}, []string{"route"})
+
+	// CacheCoalesced metric to capture the requests that waited on an identical in-flight request instead of fetching
+	CacheCoalesced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "cache",
+		Name:      "coalesced",
+		Help:      "The number of requests coalesced onto an in-flight fetch by cache",
+	}, []string{"cache"})
+
+	// RateLimited metric to capture the requests rejected by the rate limiter
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "api",
+		Name:      "rate_limited",
+		Help:      "The number of requests rejected by the rate limiter by client type",
+	}, []string{"client_type"})
+
+	// APIKeyRequests metric to capture the usage of each api key
+	APIKeyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "api",
+		Name:      "key_requests",
+		Help:      "The number of requests made by api key and tier",
+	}, []string{"key", "tier"})
+
+	// AlertMatches metric to capture the newly indexed claims matching saved searches
+	AlertMatches = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "alerts",
+		Name:      "matches",
+		Help:      "The number of newly indexed claims matching a saved search",
+	})
+
+	// AlertDeliveries metric to capture the webhook deliveries of saved search alerts
+	AlertDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "alerts",
+		Name:      "deliveries",
+		Help:      "The number of webhook deliveries by final status",
+	}, []string{"status"})
+
+	// AlertAttempts metric to capture the attempts made to deliver alerts to webhooks
+	AlertAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "alerts",
+		Name:      "attempts",
+		Help:      "The number of webhook delivery attempts by outcome",
+	}, []string{"outcome"})
+
+	// StreamSubscribers metric to capture the open streams of newly indexed claims
+	StreamSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "lighthouse",
+		Subsystem: "stream",
+		Name:      "subscribers",
+		Help:      "The number of open streams of newly indexed claims",
+	})
+
+	// StreamEvents metric to capture the newly indexed claims published to the streams
+	StreamEvents = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "stream",
+		Name:      "events",
+		Help:      "The number of newly indexed claims published to the streams",
+	})
+
+	// StreamOverflows metric to capture the streams closed for falling behind
+	StreamOverflows = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "lighthouse",
+		Subsystem: "stream",
+		Name:      "overflows",
+		Help:      "The number of streams closed because their client fell behind",
+	})
+
 	jobs = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "lighthouse",
 		Subsystem: "jobs",
@@ -48,6 +279,39 @@ var (
 	}, []string{"job"})
 )
 
+// TermCount buckets the number of terms in a query to keep the cardinality of labels low.
+func TermCount(terms int) string {
+	if terms >= 5 {
+		return "5+"
+	}
+	return strconv.Itoa(terms)
+}
+
+// Results helper function to record the quality metrics of the results of a query
+func Results(searchType string, terms, returned int) {
+	ReturnedHits.WithLabelValues(searchType).Observe(float64(returned))
+	if returned == 0 {
+		ZeroResults.WithLabelValues(searchType, TermCount(terms)).Inc()
+	}
+}
+
+// ES helper function to record the time elasticsearch took on a query against the wall-clock time since start
+func ES(start time.Time, searchType string, tookInMillis, totalHits int64) {
+	ESDuration.WithLabelValues(searchType).Observe(time.Since(start).Seconds())
+	ESTook.WithLabelValues(searchType).Observe(float64(tookInMillis) / 1000)
+	TotalHits.WithLabelValues(searchType).Observe(float64(totalHits))
+}
+
+// Incomplete records a query that returned partial results, either because it timed out or because some shards
+// failed.
+func Incomplete(searchType string, timedOut bool) {
+	reason := "shard_failure"
+	if timedOut {
+		reason = "timed_out"
+	}
+	ESIncomplete.WithLabelValues(searchType, reason).Inc()
+}
+
 //Job helper function to make tracking metric one line deferral
 func Job(start time.Time, name string) {
 	duration := time.Since(start).Seconds()
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lbryio/lighthouse/app/internal/metrics"
@@ -13,11 +14,14 @@ import (
 
 	"github.com/lbryio/lighthouse/app/db"
 	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/httpcache"
 	"github.com/lbryio/lighthouse/app/model"
+	"github.com/lbryio/lighthouse/app/tracing"
 	"github.com/lbryio/lighthouse/app/util"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/lbryinc"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
 
 	"github.com/sirupsen/logrus"
 )
@@ -53,46 +57,100 @@ var blockedClaims = []string{
 	"1fad0acce83a4006ad46788bfc3de197bf421a21",
 }
 
+var stopper = stop.New()
+
+var (
+	listedMu sync.RWMutex
+	// listed holds the claim ids, of claims and channels, on each list as of its last run.
+	listed = make(map[string]map[string]bool)
+)
+
+// IsBlocked returns whether any of the claim ids, such as those of a claim and of its channel, is on the blocked or
+// filtered lists. Listed claims are removed from the index when the lists are processed, this covers the time until
+// then.
+func IsBlocked(claimIDs ...string) bool {
+	listedMu.RLock()
+	defer listedMu.RUnlock()
+	for _, id := range claimIDs {
+		if id == "" {
+			continue
+		}
+		for _, static := range [][]string{blockedChannels, blockedClaims} {
+			for _, blocked := range static {
+				if id == blocked {
+					return true
+				}
+			}
+		}
+		for _, ids := range listed {
+			if ids[id] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Shutdown waits for running list processing to finish and prevents it from starting again.
+func Shutdown() {
+	stopper.StopAndWait()
+}
+
 // ProcessBlockedList removes any claims and channels associated with the blocked list
-func ProcessBlockedList() {
+func ProcessBlockedList() error {
 	metrics.JobLoad.WithLabelValues("blockedlist_sync").Inc()
 	defer metrics.JobLoad.WithLabelValues("blockedlist_sync").Dec()
 	defer metrics.Job(time.Now(), "blockedlist_sync")
-	processListForRemoval("list_blocked")
+	return processListForRemoval("list_blocked")
 }
 
 // ProcessFilteredList removes any claims and channels associated with the filtered list
-func ProcessFilteredList() {
+func ProcessFilteredList() error {
 	metrics.JobLoad.WithLabelValues("filteredlist_sync").Inc()
 	defer metrics.JobLoad.WithLabelValues("filteredlist_sync").Dec()
 	defer metrics.Job(time.Now(), "filteredlist_sync")
-	processListForRemoval("list_filtered")
+	return processListForRemoval("list_filtered")
 }
 
 // processListForRemoval runs through the passed list and tries to delete the entry if it exists or if its a channel to
 // delete the claims associated with it from the lighthouse elastic db.
-func processListForRemoval(list string) {
+func processListForRemoval(list string) error {
+	select {
+	case <-stopper.Ch():
+		return nil
+	default:
+	}
+	stopper.Add(1)
+	defer stopper.Done()
+	if db.Chainquery == nil {
+		return errors.Err("the claims of the %s list are looked up in chainquery, set CHAINQUERY_DSN", list)
+	}
+	ctx, span := tracing.Start(context.Background(), "job "+list)
+	defer span.End()
 	c := lbryinc.NewClient("", nil)
 	r, err := c.Call("file", list, nil)
 	if err != nil {
-		logrus.Error(errors.Err(err))
-		return
+		return errors.Err(err)
 	}
 	data, ok := r["outpoints"]
 	if !ok {
-		logrus.Error("Could not grab outputs from return for blocked list")
-		return
+		return errors.Err("Could not grab outputs from return for blocked list")
 	}
 	outpoints, ok := data.([]interface{})
 	if !ok {
-		logrus.Error("Could not convert data to string array")
-		return
+		return errors.Err("Could not convert data to string array")
 	}
-	p, err := es.Client.BulkProcessor().Name("ClaimSync").After(es.AfterBulkSend).Workers(4).Do(context.Background())
+	p, err := es.NewBulkProcessor(ctx, "BlockedSync", 4)
 	if err != nil {
-		logrus.Error(errors.Err(err))
-		return
+		return errors.Err(err)
 	}
+	ids := make(map[string]bool, len(outpoints))
+	listedMu.RLock()
+	previous := listed[list]
+	listedMu.RUnlock()
+	// newlyListed are the claims removed for being added to the list since its last run, whose cached responses are
+	// purged.
+	var newlyListed []string
 	for _, value := range outpoints {
 		outpoint, ok := value.(string)
 		if !ok {
@@ -119,6 +177,10 @@ func processListForRemoval(list string) {
 			}
 			continue
 		}
+		ids[claimID] = true
+		if !previous[claimID] {
+			newlyListed = append(newlyListed, claimID)
+		}
 		//If its a channel that is blocked, remove all of its claims as well.
 		rows, err := db.Chainquery.Query("SELECT claim_id FROM claim WHERE publisher_id =?", claimID)
 		if err != nil {
@@ -133,22 +195,27 @@ func processListForRemoval(list string) {
 				continue
 			}
 			claim.Delete(p)
+			if !previous[claimID] {
+				newlyListed = append(newlyListed, claim.ClaimID)
+			}
 		}
 		util.CloseRows(rows)
 		claim := model.NewClaim()
 		claim.ClaimID = claimID
 		claim.Delete(p)
 	}
+	listedMu.Lock()
+	listed[list] = ids
+	listedMu.Unlock()
 	removeBlockedChannels(p)
 	removedBlockedClaims(p)
 	err = p.Flush()
 	if err != nil {
-		logrus.Error(err)
-	}
-	err = p.Close()
-	if err != nil {
-		logrus.Error(err)
+		_ = p.Close()
+		return errors.Err(err)
 	}
+	httpcache.PurgeClaims(newlyListed...)
+	return errors.Err(p.Close())
 }
 
 func removeBlockedChannels(p *elastic.BulkProcessor) {
@@ -2,39 +2,63 @@ package chainquery
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lbryio/lighthouse/app/internal/metrics"
 
 	"github.com/lbryio/lighthouse/app/db"
 	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/httpcache"
+	"github.com/lbryio/lighthouse/app/jobs/source"
 	"github.com/lbryio/lighthouse/app/model"
+	"github.com/lbryio/lighthouse/app/tracing"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/null"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
 	"github.com/lbryio/lbry.go/v2/extras/util"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
 )
 
 var claimSyncRunning bool
+var syncMu sync.Mutex
+var stopper = stop.New()
 var batchSize = 1000
 var maxClaimsToProcessPerIteration = 5000
 
 // SyncStateDir holds the direction location of where to store the sync state json file.
 var SyncStateDir string
 
+// OnIndexed is called with the claim ids indexed by each scheduled sync once they are flushed to elasticsearch. The
+// claims indexed again by admins are not passed to it, so that alerts and the claim stream only see new claims.
+var OnIndexed func(claimIDs []string)
+
 func query(channelID *string) string {
 	channelFilter := ""
 	if channelID != nil {
 		channelFilter = ` AND p.claim_id = "` + util.StrFromPtr(channelID) + `" `
 	}
-	var query = `
+	var query = selectClaims + `
+WHERE c.id > ? ` + channelFilter + `
+AND c.modified_at >= ? 
+GROUP BY c.id 
+ORDER BY c.id 
+LIMIT ?`
+	return query
+}
+
+const selectClaims = `
 SELECT c.id, 
 	c.name, 
 	p.name as channel, 
@@ -63,112 +87,276 @@ SELECT c.id,
  	GROUP_CONCAT(t.tag) as tags 
 FROM claim c LEFT JOIN claim p on p.claim_id = c.publisher_id 
 LEFT JOIN claim_tag ct ON ct.claim_id = c.claim_id 
-LEFT JOIN tag t ON ct.tag_id = t.id 
-WHERE c.id > ? ` + channelFilter + `
-AND c.modified_at >= ? 
-GROUP BY c.id 
-ORDER BY c.id 
-LIMIT ?`
-	return query
+LEFT JOIN tag t ON ct.tag_id = t.id `
+
+// Shutdown signals a running sync to stop after its current batch and waits for it to save its sync state.
+func Shutdown() {
+	// Stopping under the lock keeps a sync from being added to the stopper once it is waited on.
+	syncMu.Lock()
+	stopper.Stop()
+	syncMu.Unlock()
+	stopper.Wait()
 }
 
-// Sync uses Chainquery to sync the claim information to the elasticsearch db.
-func Sync(channelID *string) {
-	if claimSyncRunning {
-		return
+// Sync syncs the claims of the Source, Chainquery by default, to the elasticsearch db. It does nothing if a sync is
+// already running.
+func Sync(channelID *string) error {
+	if beginSync() != nil {
+		return nil
 	}
+	return runSync(channelID)
+}
+
+// StartSync reserves the claim sync, returning ErrSyncRunning if a sync is already running, and returns the function
+// running the reserved sync, which must be called. It lets callers report a conflict before running the sync in the
+// background.
+func StartSync(channelID *string) (func() error, error) {
+	err := beginSync()
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return runSync(channelID) }, nil
+}
+
+// runSync runs a sync reserved with beginSync.
+func runSync(channelID *string) error {
+	defer stopper.Done()
 	metrics.JobLoad.WithLabelValues("claim_sync").Inc()
 	defer metrics.JobLoad.WithLabelValues("claim_sync").Dec()
 	defer metrics.Job(time.Now(), "claim_sync")
-	claimSyncRunning = true
 	defer endClaimSync(channelID)
+	ctx, span := tracing.Start(context.Background(), "job claim_sync")
+	defer span.End()
 	logrus.Debugf("running claim sync job...")
 	syncState, err := loadSynState()
 	if err != nil {
-		logrus.Error(err)
-		return
+		return err
+	}
+	src := claimSource()
+	if syncState.Source != src.Name() {
+		// Checkpoints of another source cannot be resumed from.
+		syncState.Source = src.Name()
+		syncState.Checkpoint = ""
+		syncState.Appended = ""
 	}
-	if syncState.StartSyncTime.IsZero() || syncState.LastID == 0 {
+	if syncState.StartSyncTime.IsZero() || syncState.Checkpoint == "" {
 		syncState.StartSyncTime = time.Now()
 	}
-	p, err := es.Client.BulkProcessor().Name("ClaimSync").After(es.AfterBulkSend).Workers(4).Do(context.Background())
+	_, appending := src.(source.Appending)
+	if appending && syncState.Checkpoint == "" {
+		// Only the claims appended since the last completed sync are read.
+		syncState.Checkpoint = syncState.Appended
+	}
+	p, err := es.NewBulkProcessor(ctx, "ClaimSync", 4)
 	if err != nil {
-		logrus.Error(errors.Err(err))
-		return
+		return errors.Err(err)
 	}
-	finished := false
-	iteration := 0
-	for !finished {
-		rows, err := db.Chainquery.Query(query(channelID), syncState.LastID, syncState.LastSyncTime, batchSize)
-		if err != nil {
-			logrus.Error(errors.Prefix("Chainquery Err:", err))
-			return
+	var indexed, changed []string
+	done := false
+	interrupted := false
+	for processed := 0; !done && processed < maxClaimsToProcessPerIteration; {
+		if stopping() {
+			logrus.Info("claim sync interrupted, saving sync state at checkpoint ", syncState.Checkpoint)
+			interrupted = true
+			break
 		}
-		var claims []model.Claim
-		claims, syncState.LastID, err = model.GetClaimsFromDBRows(rows)
+		batch, err := src.Next(ctx, source.Request{
+			Since:      syncState.LastSyncTime,
+			ChannelID:  channelID,
+			Checkpoint: syncState.Checkpoint,
+			Size:       batchSize,
+		})
 		if err != nil {
-			logrus.Error(errors.Prefix("Failed to sync: ", err))
-			return
+			_ = p.Close()
+			return err
 		}
-		for _, claim := range claims {
-			if claim.JSONValue.IsNull() {
-				logrus.Tracef("Claim: %s", claim.AsJSON())
-				logrus.Debug("Failed to process JSONValue for ", claim.ClaimID)
-				continue
-			}
-			txTime := null.NewTime(time.Unix(int64(claim.TransactionTimeUnix.Uint64), 0), true)
-			claim.TransactionTime = &txTime
-			releaseTime := null.NewTime(time.Unix(int64(claim.ReleaseTimeUnix.Uint64), 0), true)
-			claim.ReleaseTime = &releaseTime
-			if claim.ReleaseTimeUnix.IsNull() {
-				claim.ReleaseTime = claim.TransactionTime
-			}
-			claim.Tags = strings.Split(claim.TagsStr.String, ",")
-			if claim.BidState == "Spent" || claim.BidState == "Expired" {
-				claim.Delete(p)
-			} else {
-				claim.Add(p)
-			}
+		added, deleted := process(p, index.ClaimsWrite, batch.Claims)
+		indexed = append(indexed, added...)
+		changed = append(changed, added...)
+		changed = append(changed, deleted...)
+		logrus.Debugf("Processed %d claims", len(batch.Claims))
+		syncState.Checkpoint = batch.Checkpoint
+		done = batch.Done
+		processed += len(batch.Claims)
+	}
 
-		}
-		logrus.Debugf("Processed %d claims", len(claims))
-		finished = len(claims) < batchSize || (iteration*batchSize+batchSize >= maxClaimsToProcessPerIteration)
-		iteration++
+	// Flush before saving so the sync state never points past claims that were not indexed.
+	err = p.Flush()
+	if err != nil {
+		_ = p.Close()
+		return errors.Err(err)
+	}
+	err = p.Close()
+	if err != nil {
+		return errors.Err(err)
+	}
+	httpcache.PurgeClaims(changed...)
+	if OnIndexed != nil {
+		OnIndexed(indexed)
 	}
 
-	// If not finished, store last id to run again later where we left off, otherwise Update last sync time.
-	if iteration*batchSize+batchSize >= maxClaimsToProcessPerIteration {
-	} else {
-		syncState.LastID = 0
+	// If not finished, store the checkpoint to run again later where we left off, otherwise Update last sync time.
+	if !interrupted && done {
+		if appending {
+			syncState.Appended = syncState.Checkpoint
+		}
+		syncState.Checkpoint = ""
 		syncState.LastSyncTime = syncState.StartSyncTime
 	}
 
-	err = syncState.Save()
-	if err != nil {
-		logrus.Error(err)
+	return syncState.Save()
+}
+
+// process adds the claims to the named index with the bulk processor, deleting those that are spent or expired, and
+// returns the ids of the claims added and deleted.
+func process(p *elastic.BulkProcessor, name string, claims []model.Claim) (added, deleted []string) {
+	for _, claim := range claims {
+		if claim.JSONValue.IsNull() {
+			logrus.Tracef("Claim: %s", claim.AsJSON())
+			logrus.Debug("Failed to process JSONValue for ", claim.ClaimID)
+			continue
+		}
+		txTime := null.NewTime(time.Unix(int64(claim.TransactionTimeUnix.Uint64), 0), true)
+		claim.TransactionTime = &txTime
+		releaseTime := null.NewTime(time.Unix(int64(claim.ReleaseTimeUnix.Uint64), 0), true)
+		claim.ReleaseTime = &releaseTime
+		if claim.ReleaseTimeUnix.IsNull() {
+			claim.ReleaseTime = claim.TransactionTime
+		}
+		claim.Tags = strings.Split(claim.TagsStr.String, ",")
+		if claim.BidState == "Spent" || claim.BidState == "Expired" {
+			claim.DeleteFrom(p, name)
+			deleted = append(deleted, claim.ClaimID)
+		} else {
+			claim.AddTo(p, name)
+			added = append(added, claim.ClaimID)
+		}
 	}
-	err = p.Flush()
-	if err != nil {
-		logrus.Error(err)
+	return added, deleted
+}
+
+// Running returns whether a claim sync is running.
+func Running() bool {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	return claimSyncRunning
+}
+
+// beginSync marks a sync as running and adds it to the stopper, and returns ErrSyncRunning if one already is or an
+// error if the jobs are shutting down. The caller calls stopper.Done when the sync ends.
+func beginSync() error {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	if claimSyncRunning {
+		return ErrSyncRunning
 	}
-	err = p.Close()
-	if err != nil {
-		logrus.Error(err)
+	if stopping() {
+		return errors.Err("the claim sync is shutting down")
 	}
+	claimSyncRunning = true
+	stopper.Add(1)
+	return nil
 }
 
 func endClaimSync(channelID *string) {
+	syncMu.Lock()
 	claimSyncRunning = false
+	syncMu.Unlock()
+	if stopping() {
+		return
+	}
 	syncState, _ := loadSynState()
-	if syncState != nil && syncState.LastID != 0 {
-		go Sync(channelID)
+	if syncState != nil && syncState.Checkpoint != "" {
+		go func() {
+			err := Sync(channelID)
+			if err != nil {
+				logrus.Error(err)
+			}
+		}()
+	}
+}
+
+func stopping() bool {
+	select {
+	case <-stopper.Ch():
+		return true
+	default:
+		return false
 	}
 }
 
 type claimSyncState struct {
 	StartSyncTime time.Time `json:"StartSyncTime"`
 	LastSyncTime  time.Time `json:"LastSyncTime"`
-	LastID        int       `json:"LastID"`
+	// LastID is the checkpoint of sync states saved before the claim sources, it is only read.
+	LastID     int    `json:"LastID,omitempty"`
+	Source     string `json:"Source,omitempty"`
+	Checkpoint string `json:"Checkpoint,omitempty"`
+	// Appended is the checkpoint the last completed sync of an appending source ended at.
+	Appended string `json:"Appended,omitempty"`
+}
+
+// LastSyncTime returns the start time of the last sync that processed every modified claim. Claims modified after
+// it may not be indexed yet. It is zero until the first sync completes.
+func LastSyncTime() (time.Time, error) {
+	syncState, err := loadSynState()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return syncState.LastSyncTime, nil
+}
+
+// SyncState is the progress of the claim sync.
+type SyncState struct {
+	Running       bool
+	StartSyncTime time.Time
+	LastSyncTime  time.Time
+	// LastID is the Chainquery row id a sync of Chainquery stopped at.
+	LastID int
+	// Source is the name of the claim source and Checkpoint where an unfinished sync resumes from it.
+	Source     string
+	Checkpoint string
+	// LatestModified is the last time a claim was modified in Chainquery, it is only known when syncing from it.
+	LatestModified time.Time
+	// Lag is how far the last completed sync is behind LatestModified.
+	Lag string
+}
+
+// GetSyncState returns the saved state of the claim sync and, when syncing from Chainquery, how far it is behind it.
+func GetSyncState(ctx context.Context) (SyncState, error) {
+	syncState, err := loadSynState()
+	if err != nil {
+		return SyncState{}, err
+	}
+	state := SyncState{
+		Running:       Running(),
+		StartSyncTime: syncState.StartSyncTime,
+		LastSyncTime:  syncState.LastSyncTime,
+		Source:        claimSource().Name(),
+		Checkpoint:    syncState.Checkpoint,
+	}
+	if syncState.Source != "" && syncState.Source != state.Source {
+		state.Checkpoint = ""
+	}
+	if !SyncsFromChainquery() {
+		return state, nil
+	}
+	state.LastID, _ = strconv.Atoi(state.Checkpoint)
+	if db.Chainquery == nil {
+		return state, errors.Err("chainquery is not connected")
+	}
+	var latest sql.NullTime
+	err = db.Chainquery.QueryRowContext(ctx, "SELECT MAX(modified_at) FROM claim").Scan(&latest)
+	if err != nil {
+		return state, errors.Err(err)
+	}
+	state.LatestModified = latest.Time
+	if latest.Valid && latest.Time.After(state.LastSyncTime) {
+		state.Lag = latest.Time.Sub(state.LastSyncTime).Round(time.Second).String()
+	} else {
+		state.Lag = "0s"
+	}
+	return state, nil
 }
 
 func (c claimSyncState) Save() error {
@@ -202,6 +390,15 @@ func loadSynState() (*claimSyncState, error) {
 		return nil, errors.Err(err)
 	}
 	state := &claimSyncState{}
-	return state, json.Unmarshal(data, state)
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	if state.LastID != 0 && state.Checkpoint == "" {
+		state.Source = Chainquery{}.Name()
+		state.Checkpoint = strconv.Itoa(state.LastID)
+	}
+	state.LastID = 0
+	return state, nil
 	//"LastSyncTime":"2019-10-04 00:50:19","LastID":0,"StartSyncTime":"2019-10-04 00:50:19"}
 }
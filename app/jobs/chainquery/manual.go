@@ -0,0 +1,236 @@
+package chainquery
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/db"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/httpcache"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/jobs/source"
+	"github.com/lbryio/lighthouse/app/model"
+	"github.com/lbryio/lighthouse/app/tracing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+// ErrSyncRunning is returned when a sync is requested while another one is running.
+var ErrSyncRunning = errors.Base("a claim sync is already running")
+
+// ErrUnsupported is returned when the claim source cannot serve a sync or reindex that needs Chainquery.
+var ErrUnsupported = errors.Base("not supported by the claim source")
+
+// SyncRange indexes the claims of the Source, whenever they were modified. If channelID is set only the claims of the
+// channel are indexed. A range of Chainquery row ids, above fromID and up to toID or unbounded if toID is 0, can only
+// be synced from Chainquery. The sync state of the scheduled sync is left as is, and the claims are not passed to
+// OnIndexed as they were not newly indexed.
+func SyncRange(channelID *string, fromID, toID int) error {
+	err := beginSyncRange(fromID, toID)
+	if err != nil {
+		return err
+	}
+	return runSyncRange(channelID, fromID, toID)
+}
+
+// StartSyncRange reserves the claim sync like StartSync, and returns the function running SyncRange.
+func StartSyncRange(channelID *string, fromID, toID int) (func() error, error) {
+	err := beginSyncRange(fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return runSyncRange(channelID, fromID, toID) }, nil
+}
+
+// beginSyncRange checks that the source can sync the range before reserving the sync with beginSync.
+func beginSyncRange(fromID, toID int) error {
+	if !SyncsFromChainquery() && (fromID > 0 || toID > 0) {
+		return errors.Prefix("syncing a range of Chainquery row ids", ErrUnsupported)
+	}
+	return beginSync()
+}
+
+// runSyncRange runs a range sync reserved with beginSync.
+func runSyncRange(channelID *string, fromID, toID int) error {
+	defer stopper.Done()
+	defer func() {
+		syncMu.Lock()
+		claimSyncRunning = false
+		syncMu.Unlock()
+	}()
+	metrics.JobLoad.WithLabelValues("claim_sync_range").Inc()
+	defer metrics.JobLoad.WithLabelValues("claim_sync_range").Dec()
+	defer metrics.Job(time.Now(), "claim_sync_range")
+	ctx, span := tracing.Start(context.Background(), "job claim_sync_range",
+		attribute.Int("from_id", fromID), attribute.Int("to_id", toID))
+	defer span.End()
+	p, err := es.NewBulkProcessor(ctx, "ClaimSyncRange", 4)
+	if err != nil {
+		return errors.Err(err)
+	}
+	var changed []string
+	src := claimSource()
+	req := source.Request{ChannelID: channelID, Size: batchSize}
+	if fromID > 0 {
+		// Only Chainquery syncs ranges, its checkpoint is the last row id.
+		req.Checkpoint = strconv.Itoa(fromID)
+	}
+	for !stopping() {
+		batch, err := src.Next(ctx, req)
+		if err != nil {
+			_ = p.Close()
+			return err
+		}
+		inRange := batch.Claims
+		if toID > 0 {
+			inRange = inRange[:0]
+			for _, claim := range batch.Claims {
+				if int(claim.ID) <= toID {
+					inRange = append(inRange, claim)
+				}
+			}
+		}
+		added, deleted := process(p, index.ClaimsWrite, inRange)
+		changed = append(changed, added...)
+		changed = append(changed, deleted...)
+		logrus.Debugf("Processed %d claims up to checkpoint %s", len(inRange), batch.Checkpoint)
+		// Chainquery returns the claims by row id, so the range ends with the first claim past toID.
+		if batch.Done || len(inRange) < len(batch.Claims) {
+			break
+		}
+		req.Checkpoint = batch.Checkpoint
+	}
+	err = p.Flush()
+	if err != nil {
+		_ = p.Close()
+		return errors.Err(err)
+	}
+	err = p.Close()
+	if err != nil {
+		return errors.Err(err)
+	}
+	httpcache.PurgeClaims(changed...)
+	return nil
+}
+
+// Reindex indexes the claims again from Chainquery. Claims that are spent, expired or no longer in Chainquery are
+// removed from the index. It returns the ids of the claims indexed and removed, and ErrUnsupported when the claims are
+// synced from another source, which cannot be looked up by claim id. Like SyncRange, the claims are not passed to
+// OnIndexed.
+func Reindex(ctx context.Context, claimIDs []string) (indexed, removed []string, err error) {
+	if !SyncsFromChainquery() {
+		return nil, nil, errors.Prefix("reindexing claims by id", ErrUnsupported)
+	}
+	if db.Chainquery == nil {
+		return nil, nil, errors.Err("chainquery is not connected")
+	}
+	if len(claimIDs) == 0 {
+		return nil, nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(claimIDs)), ",")
+	args := make([]interface{}, len(claimIDs))
+	for i, id := range claimIDs {
+		args[i] = id
+	}
+	rows, err := db.Chainquery.QueryContext(ctx, selectClaims+`
+WHERE c.claim_id IN (`+placeholders+`)
+GROUP BY c.id`, args...)
+	if err != nil {
+		return nil, nil, errors.Prefix("Chainquery Err:", err)
+	}
+	claims, _, err := model.GetClaimsFromDBRows(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := es.NewBulkProcessor(ctx, "ClaimReindex", 1)
+	if err != nil {
+		return nil, nil, errors.Err(err)
+	}
+	indexed, removed = process(p, index.ClaimsWrite, claims)
+	found := make(map[string]bool, len(claims))
+	for _, claim := range claims {
+		found[claim.ClaimID] = true
+	}
+	for _, id := range claimIDs {
+		if !found[id] {
+			p.Add(elastic.NewBulkDeleteRequest().Index(index.ClaimsWrite).Type(index.ClaimType).Id(id))
+			removed = append(removed, id)
+		}
+	}
+	err = p.Flush()
+	if err != nil {
+		_ = p.Close()
+		return nil, nil, errors.Err(err)
+	}
+	err = p.Close()
+	if err != nil {
+		return nil, nil, errors.Err(err)
+	}
+	httpcache.PurgeClaims(append(indexed, removed...)...)
+	return indexed, removed, nil
+}
+
+// ResetSyncState clears the saved sync state, so that the next sync indexes every claim again.
+func ResetSyncState() error {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	if claimSyncRunning {
+		return ErrSyncRunning
+	}
+	// Loading the state sets the directory it is saved in.
+	_, err := loadSynState()
+	if err != nil {
+		return err
+	}
+	return claimSyncState{}.Save()
+}
+
+// Fill indexes the claims of the Source modified since the time given, or every claim if it is zero, into the named
+// index instead of the write alias. The sync state is left as is. It is used to build and catch up a new version of
+// the claims index, and returns the number of claims indexed.
+func Fill(ctx context.Context, name string, since time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "job claim_fill", attribute.String("index", name))
+	defer span.End()
+	p, err := es.NewBulkProcessor(ctx, "ClaimFill", 4)
+	if err != nil {
+		return 0, errors.Err(err)
+	}
+	src := claimSource()
+	req := source.Request{Since: since, Size: batchSize}
+	count := 0
+	for {
+		if ctx.Err() != nil {
+			_ = p.Close()
+			return count, errors.Err(ctx.Err())
+		}
+		batch, err := src.Next(ctx, req)
+		if err != nil {
+			_ = p.Close()
+			return count, err
+		}
+		added, _ := process(p, name, batch.Claims)
+		count += len(added)
+		logrus.Debugf("Filled %d claims into %s", count, name)
+		if batch.Done {
+			break
+		}
+		req.Checkpoint = batch.Checkpoint
+	}
+	err = p.Flush()
+	if err != nil {
+		_ = p.Close()
+		return count, errors.Err(err)
+	}
+	err = p.Close()
+	if err != nil {
+		return count, errors.Err(err)
+	}
+	return count, nil
+}
@@ -0,0 +1,72 @@
+package chainquery
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/lbryio/lighthouse/app/db"
+	"github.com/lbryio/lighthouse/app/jobs/source"
+	"github.com/lbryio/lighthouse/app/model"
+	"github.com/lbryio/lighthouse/app/tracing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Source is the source of the claims synced, Chainquery if nil.
+var Source source.ClaimSource
+
+// Chainquery reads the claims modified since the last sync from the Chainquery database, by row id. The checkpoint
+// is the row id of the last claim returned.
+type Chainquery struct{}
+
+// Name implements source.ClaimSource.
+func (Chainquery) Name() string {
+	return "chainquery"
+}
+
+// Next implements source.ClaimSource.
+func (Chainquery) Next(ctx context.Context, req source.Request) (source.Batch, error) {
+	lastID := 0
+	if req.Checkpoint != "" {
+		var err error
+		lastID, err = strconv.Atoi(req.Checkpoint)
+		if err != nil {
+			return source.Batch{}, errors.Err("invalid chainquery checkpoint %q", req.Checkpoint)
+		}
+	}
+	if db.Chainquery == nil {
+		return source.Batch{}, errors.Err("chainquery is not connected")
+	}
+	ctx, span := tracing.Start(ctx, "chainquery.query",
+		attribute.Int("last_id", lastID),
+		attribute.Int("batch_size", req.Size))
+	rows, err := db.Chainquery.QueryContext(ctx, query(req.ChannelID), lastID, req.Since, req.Size)
+	if err != nil {
+		tracing.End(span, err)
+		return source.Batch{}, errors.Prefix("Chainquery Err:", err)
+	}
+	claims, id, err := model.GetClaimsFromDBRows(rows)
+	tracing.End(span, err)
+	if err != nil {
+		return source.Batch{}, errors.Prefix("Failed to sync: ", err)
+	}
+	if len(claims) > 0 {
+		lastID = id
+	}
+	return source.Batch{Claims: claims, Checkpoint: strconv.Itoa(lastID), Done: len(claims) < req.Size}, nil
+}
+
+func claimSource() source.ClaimSource {
+	if Source == nil {
+		return Chainquery{}
+	}
+	return Source
+}
+
+// SyncsFromChainquery returns whether the claims are synced from Chainquery rather than another source.
+func SyncsFromChainquery() bool {
+	_, ok := claimSource().(Chainquery)
+	return ok
+}
@@ -1,7 +1,10 @@
 package jobs
 
 import (
+	"time"
+
 	"github.com/jasonlvhit/gocron"
+	"github.com/lbryio/lighthouse/app/alerts"
 	"github.com/lbryio/lighthouse/app/jobs/blocked"
 	"github.com/lbryio/lighthouse/app/jobs/chainquery"
 	"github.com/lbryio/lighthouse/app/jobs/internalapis"
@@ -14,18 +17,34 @@ var scheduler *gocron.Scheduler
 // Start starts the jobs that run in the background after initialization
 func Start() {
 	scheduler = gocron.NewScheduler()
-	var channels *string
-	scheduler.Every(15).Minutes().Do(chainquery.Sync, channels)
-	scheduler.Every(6).Hours().Do(internalapis.Sync)
-	scheduler.Every(1).Minutes().Do(blocked.ProcessBlockedList)
-	scheduler.Every(1).Minutes().Do(blocked.ProcessFilteredList)
+	schedule("claim_sync", 15*time.Minute, func() error { return chainquery.Sync(nil) })
+	schedule("internalapis_sync", 6*time.Hour, internalapis.Sync)
+	schedule("blockedlist_sync", time.Minute, blocked.ProcessBlockedList)
+	schedule("filteredlist_sync", time.Minute, blocked.ProcessFilteredList)
 
 	cronRunning = scheduler.Start()
 }
 
-// Shutdown is used to shutdown the background jobs.
+// schedule runs the job every interval, recording each run for Status.
+func schedule(name string, interval time.Duration, run func() error) {
+	j := &job{status: Status{Name: name, Interval: interval.String()}, interval: interval, scheduled: time.Now()}
+	registryMu.Lock()
+	registry = append(registry, j)
+	registryMu.Unlock()
+	scheduler.Every(uint64(interval/time.Second)).Seconds().Do(j.run, run)
+}
+
+// Shutdown is used to shutdown the background jobs. It stops scheduling new runs and waits for running jobs to reach
+// a checkpoint, flush their bulk processors and save their state.
 func Shutdown() {
 	logrus.Debug("Shutting down cron jobs...")
-	scheduler.Clear()
-	close(cronRunning)
+	if scheduler != nil {
+		close(cronRunning)
+		scheduler.Clear()
+	}
+	chainquery.Shutdown()
+	alerts.Shutdown()
+	internalapis.Shutdown()
+	blocked.Shutdown()
+	logrus.Debug("Cron jobs shut down")
 }
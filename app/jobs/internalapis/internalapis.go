@@ -1,9 +1,16 @@
 package internalapis
 
 import (
+	"context"
 	"time"
 
 	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/tracing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	"github.com/sirupsen/logrus"
 )
 
 // APIURL is the url for internal-apis to be used by lighthouse
@@ -13,24 +20,52 @@ var APIURL string
 var APIToken string
 
 var incSyncRunning bool
+var stopper = stop.New()
 
 const batchSize = 1000
 
+// Shutdown signals a running sync to stop after its current batch and waits for it to finish.
+func Shutdown() {
+	stopper.StopAndWait()
+}
+
 // Sync synchronizes view and subscription counts from internal-apis
-func Sync() {
-	if incSyncRunning {
-		return
+func Sync() error {
+	if incSyncRunning || stopping() {
+		return nil
 	}
+	stopper.Add(1)
+	defer stopper.Done()
 	metrics.JobLoad.WithLabelValues("internalapis_sync").Inc()
 	defer metrics.JobLoad.WithLabelValues("internalapis_sync").Dec()
 	defer metrics.Job(time.Now(), "internalapis_sync")
 	incSyncRunning = true
 	defer endIncSync()
-	syncSubCounts()
-	syncViewCounts()
-
+	ctx, span := tracing.Start(context.Background(), "job internalapis_sync")
+	defer span.End()
+	subErr := syncSubCounts(ctx)
+	if subErr != nil {
+		logrus.Error(subErr)
+	}
+	err := syncViewCounts(ctx)
+	if err != nil {
+		return errors.Prefix("view count sync failed", err)
+	}
+	if subErr != nil {
+		return errors.Prefix("sub count sync failed", subErr)
+	}
+	return nil
 }
 
 func endIncSync() {
 	incSyncRunning = false
 }
+
+func stopping() bool {
+	select {
+	case <-stopper.Ch():
+		return true
+	default:
+		return false
+	}
+}
@@ -29,22 +29,21 @@ type subCntResponse struct {
 	Data    map[string]int64 `json:"data"`
 }
 
-func syncSubCounts() {
+func syncSubCounts(ctx context.Context) error {
 	s := elastic.NewSearchSource()
 	s.Query(search.ChannelOnlyMatch)
 	s.FetchSourceContext(elastic.NewFetchSourceContext(false))
 	s.Size(batchSize)
 	scroll := es.Client.Scroll(index.Claims).SearchSource(s).Scroll("10m")
-	p, err := es.Client.BulkProcessor().Name("IncSync").After(es.AfterBulkSend).Workers(2).Do(context.Background())
+	p, err := es.NewBulkProcessor(ctx, "SubCountSync", 2)
 	if err != nil {
-		logrus.Error(errors.Err(err))
-		return
+		return errors.Err(err)
 	}
 
 	finished := false
 	iteration := 0
 	for !finished {
-		result, err := scroll.Do(context.Background())
+		result, err := scroll.Do(ctx)
 		if err != nil && !errors.Is(err, io.EOF) {
 			logrus.Error(errors.Prefix(fmt.Sprintf("inc batch %d failed:", iteration+1), err))
 			continue
@@ -64,20 +63,18 @@ func syncSubCounts() {
 		if iteration%10 == 0 {
 			logrus.Debugf("Processed %d claims", iteration*batchSize)
 		}
-		finished = len(result.Hits.Hits) < batchSize
+		finished = len(result.Hits.Hits) < batchSize || stopping()
 	}
-	err = scroll.Clear(context.Background())
+	err = scroll.Clear(ctx)
 	if err != nil {
 		logrus.Error(errors.Err(err))
 	}
 	err = p.Flush()
 	if err != nil {
-		logrus.Error(errors.Err(err))
-	}
-	err = p.Close()
-	if err != nil {
-		logrus.Error(errors.Err(err))
+		_ = p.Close()
+		return errors.Err(err)
 	}
+	return errors.Err(p.Close())
 }
 
 func updateSubCounts(claimIDs []string, iteration int, p *elastic.BulkProcessor) error {
@@ -0,0 +1,113 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/model"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// File reads the claims from a file of newline-delimited JSON, one claim per line in the form it is indexed in, as
+// sent by the export of /search. The checkpoint is the offset in the file of the next line to read. Claims are
+// appended to the file, so each sync resumes from the offset where the previous one ended and only reads the lines
+// appended since. The file is read from the start by full syncs and when it is replaced by a shorter one, then lines
+// may set `modified_at` to be skipped by syncs started after it.
+type File struct {
+	Path string
+}
+
+// NewFile returns a source reading the claims from the file at path.
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+// Name implements ClaimSource.
+func (f *File) Name() string {
+	return "file"
+}
+
+// Appending implements Appending.
+func (f *File) Appending() {}
+
+type fileClaim struct {
+	model.Claim
+	ModifiedAt *time.Time `json:"modified_at"`
+}
+
+// Next implements ClaimSource.
+func (f *File) Next(ctx context.Context, req Request) (Batch, error) {
+	var offset int64
+	if req.Checkpoint != "" {
+		var err error
+		offset, err = strconv.ParseInt(req.Checkpoint, 10, 64)
+		if err != nil {
+			return Batch{}, errors.Err("invalid file checkpoint %q", req.Checkpoint)
+		}
+	}
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return Batch{}, errors.Err(err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() < offset {
+		// The file was replaced by a shorter one, read it from the start.
+		offset = 0
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return Batch{}, errors.Err(err)
+	}
+	reader := bufio.NewReader(file)
+	batch := Batch{Claims: make([]model.Claim, 0)}
+	for len(batch.Claims) < req.Size && ctx.Err() == nil {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && (len(line) == 0 || line[len(line)-1] != '\n') {
+			// A partial last line is read once it is complete.
+			batch.Done = true
+			break
+		}
+		if err != nil {
+			return Batch{}, errors.Err(err)
+		}
+		lineOffset := offset
+		offset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		claim := fileClaim{Claim: model.NewClaim()}
+		err = json.Unmarshal(line, &claim)
+		if err != nil {
+			return Batch{}, errors.Prefix("claim at offset "+strconv.FormatInt(lineOffset, 10)+" of "+f.Path, err)
+		}
+		if claim.ModifiedAt != nil && claim.ModifiedAt.Before(req.Since) {
+			continue
+		}
+		if req.ChannelID != nil && (claim.ChannelClaimID == nil || claim.ChannelClaimID.String != *req.ChannelID) {
+			continue
+		}
+		err = claim.PopulateFromDocument()
+		if err != nil {
+			return Batch{}, err
+		}
+		batch.Claims = append(batch.Claims, claim.Claim)
+	}
+	if ctx.Err() != nil {
+		return Batch{}, errors.Err(ctx.Err())
+	}
+	if !batch.Done {
+		// Peek so that a batch ending with the file is the last one.
+		_, err := reader.Peek(1)
+		batch.Done = err == io.EOF
+	}
+	batch.Checkpoint = strconv.FormatInt(offset, 10)
+	return batch, nil
+}
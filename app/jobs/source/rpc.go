@@ -0,0 +1,290 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/model"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/null"
+	"github.com/lbryio/lbry.go/v2/extras/util"
+)
+
+// MaxPageSize is the largest page claim_search returns.
+const MaxPageSize = 50
+
+// nsfwTags are the tags the SDK marks claims as mature with.
+var nsfwTags = map[string]bool{"mature": true, "xxx": true, "sex": true, "porn": true, "nsfw": true}
+
+// RPC pages through the claim_search method of the JSON-RPC API of the SDK or a hub, by ascending height. Claims
+// created or updated since the last sync are returned, claims that are only supported or abandoned are not, as
+// claim_search cannot tell. The checkpoint is the height the next batch starts at, so that claims confirmed while
+// paging do not shift the pages, followed by the page within that height when a whole page is at one height.
+type RPC struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewRPC returns a source calling claim_search on the JSON-RPC API at url.
+func NewRPC(url string) *RPC {
+	return &RPC{URL: url, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Name implements ClaimSource.
+func (s *RPC) Name() string {
+	return "rpc"
+}
+
+type rpcRequest struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params"`
+	ID      int                    `json:"id"`
+}
+
+type rpcResponse struct {
+	Result *rpcResult `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type rpcResult struct {
+	Items      []rpcClaim `json:"items"`
+	Page       int        `json:"page"`
+	TotalPages int        `json:"total_pages"`
+}
+
+type rpcMeta struct {
+	EffectiveAmount string `json:"effective_amount"`
+	IsControlling   bool   `json:"is_controlling"`
+	ClaimsInChannel uint64 `json:"claims_in_channel"`
+}
+
+type rpcClaim struct {
+	Name           string                 `json:"name"`
+	ClaimID        string                 `json:"claim_id"`
+	ValueType      string                 `json:"value_type"`
+	Timestamp      int64                  `json:"timestamp"`
+	Height         int64                  `json:"height"`
+	Value          map[string]interface{} `json:"value"`
+	Meta           rpcMeta                `json:"meta"`
+	SignatureValid bool                   `json:"is_channel_signature_valid"`
+	SigningChannel *struct {
+		Name    string  `json:"name"`
+		ClaimID string  `json:"claim_id"`
+		Meta    rpcMeta `json:"meta"`
+	} `json:"signing_channel"`
+}
+
+// Next implements ClaimSource.
+func (s *RPC) Next(ctx context.Context, req Request) (Batch, error) {
+	height, page, err := parseRPCCheckpoint(req.Checkpoint)
+	if err != nil {
+		return Batch{}, err
+	}
+	size := req.Size
+	if size > MaxPageSize || size <= 0 {
+		size = MaxPageSize
+	}
+	params := map[string]interface{}{
+		"page":      page,
+		"page_size": size,
+		"order_by":  []string{"^height"},
+	}
+	if height > 0 {
+		params["height"] = ">=" + strconv.FormatInt(height, 10)
+	}
+	if !req.Since.IsZero() {
+		params["timestamp"] = ">=" + strconv.FormatInt(req.Since.Unix(), 10)
+	}
+	if req.ChannelID != nil {
+		params["channel_ids"] = []string{*req.ChannelID}
+	}
+	res, err := s.call(ctx, "claim_search", params)
+	if err != nil {
+		return Batch{}, err
+	}
+	items := res.Items
+	// Hubs asked not to count the results return no total_pages.
+	done := len(items) < size || res.TotalPages > 0 && page >= res.TotalPages
+	checkpoint := strconv.FormatInt(height, 10)
+	if len(items) > 0 {
+		first, last := items[0].Height, items[len(items)-1].Height
+		checkpoint = strconv.FormatInt(last, 10)
+		if !done && first < last {
+			// The claims of the last height are returned by the next batch, which starts at it.
+			for len(items) > 0 && items[len(items)-1].Height == last {
+				items = items[:len(items)-1]
+			}
+		} else if !done {
+			// The whole page is at one height, the next batch is the next page of it.
+			next := 2
+			if last == height {
+				next = page + 1
+			}
+			checkpoint += ":" + strconv.Itoa(next)
+		}
+	}
+	batch := Batch{Claims: make([]model.Claim, 0, len(items)), Checkpoint: checkpoint, Done: done}
+	for _, item := range items {
+		claim, err := item.claim()
+		if err != nil {
+			return Batch{}, err
+		}
+		batch.Claims = append(batch.Claims, claim)
+	}
+	return batch, nil
+}
+
+// parseRPCCheckpoint returns the height and the page within it of the checkpoint, `height` or `height:page`.
+func parseRPCCheckpoint(checkpoint string) (height int64, page int, err error) {
+	page = 1
+	if checkpoint == "" {
+		return 0, page, nil
+	}
+	parts := strings.SplitN(checkpoint, ":", 2)
+	height, err = strconv.ParseInt(parts[0], 10, 64)
+	if err == nil && len(parts) == 2 {
+		page, err = strconv.Atoi(parts[1])
+	}
+	if err != nil || height < 0 || page < 1 {
+		return 0, 0, errors.Err("invalid rpc checkpoint %q", checkpoint)
+	}
+	return height, page, nil
+}
+
+func (s *RPC) call(ctx context.Context, method string, params map[string]interface{}) (*rpcResult, error) {
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: 1})
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpRes, err := s.Client.Do(httpReq)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	defer httpRes.Body.Close()
+	if httpRes.StatusCode != http.StatusOK {
+		return nil, errors.Err("%s returned %s", method, httpRes.Status)
+	}
+	var res rpcResponse
+	err = json.NewDecoder(httpRes.Body).Decode(&res)
+	if err != nil {
+		return nil, errors.Prefix(method, err)
+	}
+	if res.Error != nil {
+		return nil, errors.Err("%s failed: %s (%d)", method, res.Error.Message, res.Error.Code)
+	}
+	if res.Result == nil {
+		return nil, errors.Err("%s returned no result", method)
+	}
+	return res.Result, nil
+}
+
+// claim maps a claim_search result to the claim as read from Chainquery.
+func (c rpcClaim) claim() (model.Claim, error) {
+	claim := model.NewClaim()
+	claim.Name = c.Name
+	claim.ClaimID = c.ClaimID
+	claim.ClaimType = util.PtrToNullString(c.ValueType)
+	claim.CertValid = c.SignatureValid
+	claim.BidState = "Active"
+	if c.Meta.IsControlling {
+		claim.BidState = "Controlling"
+	}
+	claim.EffectiveAmount = dewies(c.Meta.EffectiveAmount)
+	claim.Value = c.Value
+	if c.Timestamp > 0 {
+		txTime := null.TimeFrom(time.Unix(c.Timestamp, 0))
+		claim.TransactionTime = &txTime
+	}
+	if c.SigningChannel != nil {
+		claim.Channel = util.PtrToNullString(c.SigningChannel.Name)
+		claim.ChannelClaimID = util.PtrToNullString(c.SigningChannel.ClaimID)
+		claim.CertificateAmount = dewies(c.SigningChannel.Meta.EffectiveAmount)
+		claim.ClaimCount = c.SigningChannel.Meta.ClaimsInChannel
+	}
+	if c.ValueType == "channel" {
+		claim.ClaimCount = c.Meta.ClaimsInChannel
+	}
+	claim.Title = util.PtrToNullString(str(c.Value, "title"))
+	claim.Description = util.PtrToNullString(str(c.Value, "description"))
+	claim.ContentType = util.PtrToNullString(str(c.Value, "source", "media_type"))
+	claim.ThumbnailURL = util.PtrToNullString(str(c.Value, "thumbnail", "url"))
+	if releaseTime, err := strconv.ParseInt(str(c.Value, "release_time"), 10, 64); err == nil {
+		t := null.TimeFrom(time.Unix(releaseTime, 0))
+		claim.ReleaseTime = &t
+	}
+	claim.FrameWidth = util.PtrToNullUint64(num(c.Value, "video", "width"))
+	claim.FrameHeight = util.PtrToNullUint64(num(c.Value, "video", "height"))
+	duration := num(c.Value, "video", "duration")
+	if duration == 0 {
+		duration = num(c.Value, "audio", "duration")
+	}
+	claim.Duration = util.PtrToNullUint64(duration)
+	if fee, err := strconv.ParseFloat(str(c.Value, "fee", "amount"), 64); err == nil {
+		claim.Fee = util.PtrToNullFloat64(fee)
+	}
+	if tags, ok := c.Value["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if t, ok := tag.(string); ok {
+				claim.Tags = append(claim.Tags, t)
+				claim.NSFW = claim.NSFW || nsfwTags[strings.ToLower(t)]
+			}
+		}
+	}
+	err := claim.PopulateFromDocument()
+	return claim, err
+}
+
+// dewies converts an amount in LBC, as returned by the SDK, to dewies.
+func dewies(lbc string) uint64 {
+	amount, err := strconv.ParseFloat(lbc, 64)
+	if err != nil || amount < 0 {
+		return 0
+	}
+	return uint64(math.Round(amount * 1e8))
+}
+
+// str returns the string at the path of the value, or an empty string.
+func str(value map[string]interface{}, path ...string) string {
+	v, _ := lookup(value, path).(string)
+	return v
+}
+
+// num returns the number at the path of the value, or 0.
+func num(value map[string]interface{}, path ...string) uint64 {
+	switch v := lookup(value, path).(type) {
+	case float64:
+		return uint64(v)
+	case string:
+		n, _ := strconv.ParseUint(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
+func lookup(value map[string]interface{}, path []string) interface{} {
+	var v interface{} = value
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
@@ -0,0 +1,48 @@
+package source
+
+import (
+	"context"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/model"
+)
+
+// Request asks a source for the next batch of claims.
+type Request struct {
+	// Since is the time of the last completed sync, only the claims modified since are returned if the source can
+	// tell. It is zero for a full sync.
+	Since time.Time
+	// ChannelID restricts the claims to those of a channel if set.
+	ChannelID *string
+	// Checkpoint is where the previous batch ended, empty to start from the beginning.
+	Checkpoint string
+	// Size is the maximum number of claims returned.
+	Size int
+}
+
+// Batch is a batch of claims returned by a source.
+type Batch struct {
+	Claims []model.Claim
+	// Checkpoint is passed in the next request to resume after this batch. It is saved in the sync state, so that an
+	// interrupted sync resumes where it stopped.
+	Checkpoint string
+	// Done is set when there are no claims after this batch.
+	Done bool
+}
+
+// ClaimSource yields the claims indexed by the claim sync, in batches. Claims are returned as read from Chainquery,
+// with JSONValue, TransactionTimeUnix, ReleaseTimeUnix and TagsStr set, see model.Claim.PopulateFromDocument.
+type ClaimSource interface {
+	// Name identifies the source in the sync state, checkpoints of another source are not resumed from.
+	Name() string
+	// Next returns the batch of claims after the checkpoint of the request.
+	Next(ctx context.Context, req Request) (Batch, error)
+}
+
+// Appending is implemented by sources that claims are only ever appended to, such as a file. A completed sync saves
+// the checkpoint it ended at and the next sync resumes from it, instead of reading every claim again.
+type Appending interface {
+	ClaimSource
+	// Appending marks the source as appending.
+	Appending()
+}
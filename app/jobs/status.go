@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Status is the schedule and the outcome of the last run of a background job.
+type Status struct {
+	Name     string
+	Interval string
+	Running  bool
+	Runs     int
+	// LastStart and LastEnd are zero until the job first starts and ends.
+	LastStart    time.Time
+	LastEnd      time.Time
+	LastDuration string
+	// LastOutcome is success or error, empty until the job first ends.
+	LastOutcome string
+	LastError   string
+	NextRun     time.Time
+}
+
+type job struct {
+	mu        sync.Mutex
+	status    Status
+	interval  time.Duration
+	scheduled time.Time
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*job
+)
+
+func (j *job) run(run func() error) {
+	start := time.Now()
+	j.mu.Lock()
+	j.status.Running = true
+	j.status.LastStart = start
+	j.mu.Unlock()
+
+	err := run()
+	if err != nil {
+		logrus.Errorf("job %s failed: %s", j.status.Name, err)
+	}
+
+	end := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastEnd = end
+	j.status.LastDuration = end.Sub(start).String()
+	j.status.LastOutcome = "success"
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastOutcome = "error"
+		j.status.LastError = err.Error()
+	}
+}
+
+// nextRun mirrors the scheduler, which runs a job one interval after it last started, skipping the runs it missed
+// while the job was running.
+func (j *job) nextRun() time.Time {
+	if j.status.LastStart.IsZero() {
+		return j.scheduled.Add(j.interval)
+	}
+	next := j.status.LastStart.Add(j.interval)
+	for !j.status.Running && next.Before(j.status.LastEnd) {
+		next = next.Add(j.interval)
+	}
+	return next
+}
+
+// GetStatus returns the status of each scheduled job in the order they were scheduled.
+func GetStatus() []Status {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	statuses := make([]Status, len(registry))
+	for i, j := range registry {
+		j.mu.Lock()
+		statuses[i] = j.status
+		statuses[i].NextRun = j.nextRun()
+		j.mu.Unlock()
+	}
+	return statuses
+}
@@ -93,7 +93,7 @@ func GetClaimsFromDBRows(rows *sql.Rows) ([]Claim, int, error) {
 			}
 		}
 		claim.Value = value
-		claim.StrippedName = getStrippedName(claim.Name)
+		claim.StrippedName = StripName(claim.Name)
 		lastID = int(claim.ID)
 		claims = append(claims, claim)
 	}
@@ -138,21 +138,55 @@ func (c *Claim) PopulateFromDB(rows *sql.Rows) error {
 	return err
 }
 
+// PopulateFromDocument sets the fields read from Chainquery from the fields of the claim in its document form, so
+// that claims from other sources are indexed the same way.
+func (c *Claim) PopulateFromDocument() error {
+	if c.Value == nil {
+		c.Value = map[string]interface{}{}
+	}
+	value, err := json.Marshal(c.Value)
+	if err != nil {
+		return errors.Err(err)
+	}
+	c.JSONValue = null.StringFrom(string(value))
+	if c.TransactionTime != nil && c.TransactionTime.Valid && !c.TransactionTime.Time.IsZero() {
+		c.TransactionTimeUnix = null.Uint64From(uint64(c.TransactionTime.Time.Unix()))
+	}
+	if c.ReleaseTime != nil && c.ReleaseTime.Valid && !c.ReleaseTime.Time.IsZero() {
+		c.ReleaseTimeUnix = null.Uint64From(uint64(c.ReleaseTime.Time.Unix()))
+	}
+	if len(c.Tags) > 0 {
+		c.TagsStr = util.PtrToNullString(strings.Join(c.Tags, ","))
+	}
+	c.StrippedName = StripName(c.Name)
+	return nil
+}
+
 // Add Inserts the claim as a document via the bulk processor into elasticsearch
 func (c Claim) Add(p *elastic.BulkProcessor) {
-	r := elastic.NewBulkIndexRequest().Index(index.Claims).Type(index.ClaimType).Id(c.ClaimID).Doc(c)
+	c.AddTo(p, index.ClaimsWrite)
+}
+
+// AddTo inserts the claim as a document via the bulk processor into the named index.
+func (c Claim) AddTo(p *elastic.BulkProcessor, name string) {
+	r := elastic.NewBulkIndexRequest().Index(name).Type(index.ClaimType).Id(c.ClaimID).Doc(c)
 	p.Add(r)
 }
 
 // Delete removes the claim via the bulk processor from elasticsearch
 func (c Claim) Delete(p *elastic.BulkProcessor) {
-	r := elastic.NewBulkDeleteRequest().Index(index.Claims).Type(index.ClaimType).Id(c.ClaimID)
+	c.DeleteFrom(p, index.ClaimsWrite)
+}
+
+// DeleteFrom removes the claim via the bulk processor from the named index.
+func (c Claim) DeleteFrom(p *elastic.BulkProcessor, name string) {
+	r := elastic.NewBulkDeleteRequest().Index(name).Type(index.ClaimType).Id(c.ClaimID)
 	p.Add(r)
 }
 
 // Update updates just the fields modified or with default values via the bulk processor in elasticsearch
 func (c Claim) Update(p *elastic.BulkProcessor) {
-	r := elastic.NewBulkUpdateRequest().Index(index.Claims).Type(index.ClaimType).Id(c.ClaimID).Doc(c)
+	r := elastic.NewBulkUpdateRequest().Index(index.ClaimsWrite).Type(index.ClaimType).Id(c.ClaimID).Doc(c)
 	p.Add(r)
 }
 
@@ -175,7 +209,8 @@ var replacement = map[string]string{
 	"&":   "",
 }
 
-func getStrippedName(name string) string {
+// StripName returns the name without the separators and articles ignored when matching names.
+func StripName(name string) string {
 	var replacements []string
 	for k, v := range replacement {
 		replacements = append(replacements, k, v)
@@ -0,0 +1,385 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/util"
+	v "github.com/lbryio/ozzo-validation"
+)
+
+// Endpoint documents a route of the api.
+type Endpoint struct {
+	Path        string
+	Method      string
+	Summary     string
+	Description string
+	// Params is a pointer to the struct the handler parses its parameters into with api.FormValues, nil if the
+	// handler takes no parameters.
+	Params interface{}
+	// Rules are the validation rules the handler passes to api.FormValues for Params.
+	Rules []*v.FieldRules
+	// Body is a pointer to the struct the handler decodes its json request body into, nil if it takes none.
+	Body interface{}
+	// Response is a value of the type of the data the handler responds with.
+	Response interface{}
+	// ContentType of the response, application/json unless set.
+	ContentType string
+}
+
+// Document is an OpenAPI 3 document.
+type Document map[string]interface{}
+
+// Generate builds the OpenAPI 3 document of the endpoints. Parameters are named the way api.FormValues reads them
+// and their constraints are taken from the validation rules of each endpoint.
+func Generate(title, version string, endpoints []Endpoint) Document {
+	paths := make(map[string]interface{})
+	for _, e := range endpoints {
+		method := strings.ToLower(e.Method)
+		if method == "" {
+			method = "get"
+		}
+		contentType := e.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		responseSchema := map[string]interface{}{"type": "string"}
+		if contentType == "application/json" {
+			responseSchema = Schema(reflect.TypeOf(e.Response))
+		}
+		operation := map[string]interface{}{
+			"summary": e.Summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "success",
+					"content":     map[string]interface{}{contentType: map[string]interface{}{"schema": responseSchema}},
+				},
+				"default": map[string]interface{}{
+					"description": "error",
+					"content": map[string]interface{}{"application/json": map[string]interface{}{
+						"schema": errorSchema,
+					}},
+				},
+			},
+		}
+		if e.Description != "" {
+			operation["description"] = e.Description
+		}
+		parameters := pathParameters(e.Path)
+		if e.Params != nil {
+			parameters = append(parameters, Parameters(e.Params, e.Rules)...)
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if e.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": Schema(reflect.TypeOf(e.Body))}},
+			}
+		}
+		item, ok := paths[e.Path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[e.Path] = item
+		}
+		item[method] = operation
+	}
+	return Document{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": title, "version": version},
+		"paths":   paths,
+	}
+}
+
+var errorSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success": map[string]interface{}{"type": "boolean"},
+		"error":   map[string]interface{}{"type": "string"},
+		"data":    map[string]interface{}{"nullable": true},
+	},
+}
+
+// pathParameters documents the {name} segments of the path as required string parameters.
+func pathParameters(path string) []map[string]interface{} {
+	var parameters []map[string]interface{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     strings.Trim(segment, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return parameters
+}
+
+// ParamName returns the name of the parameter api.FormValues reads into the field, empty for unexported fields which
+// are not parameters.
+func ParamName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	if name, ok := field.Tag.Lookup("json"); ok {
+		return name
+	}
+	return util.Underscore(field.Name)
+}
+
+// Parameters documents the query parameters read into the struct params points to, with the constraints of the
+// rules that apply to each of its fields.
+func Parameters(params interface{}, rules []*v.FieldRules) []map[string]interface{} {
+	value := reflect.ValueOf(params).Elem()
+	constraints := ruleConstraints(rules)
+	var parameters []map[string]interface{}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := ParamName(field)
+		if name == "" {
+			continue
+		}
+		schema := Schema(field.Type)
+		delete(schema, "nullable")
+		param := map[string]interface{}{"name": name, "in": "query", "schema": schema}
+		if c, ok := constraints[value.Field(i).Addr().Pointer()]; ok {
+			for k, v := range c.schema {
+				schema[k] = v
+			}
+			if c.required {
+				param["required"] = true
+			}
+			if c.description != "" {
+				param["description"] = c.description
+			}
+		}
+		parameters = append(parameters, param)
+	}
+	return parameters
+}
+
+type constraint struct {
+	required    bool
+	description string
+	schema      map[string]interface{}
+}
+
+// ruleConstraints reads the constraints of the rules by field address. The rules of ozzo-validation do not export
+// their settings so they are read through reflection, rules it does not know about only add their error message to
+// the description of the parameter.
+func ruleConstraints(rules []*v.FieldRules) map[uintptr]*constraint {
+	constraints := make(map[uintptr]*constraint)
+	for _, fieldRules := range rules {
+		fr := reflect.ValueOf(fieldRules).Elem()
+		c := &constraint{schema: make(map[string]interface{})}
+		constraints[fr.FieldByName("fieldPtr").Elem().Pointer()] = c
+		list := fr.FieldByName("rules")
+		for i := 0; i < list.Len(); i++ {
+			rule := list.Index(i).Elem()
+			if rule.Kind() != reflect.Ptr || rule.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			fields := rule.Elem()
+			switch rule.Type().Elem().Name() {
+			case "requiredRule":
+				c.required = true
+			case "lengthRule":
+				if min := fields.FieldByName("min").Int(); min > 0 {
+					c.schema["minLength"] = min
+				}
+				if max := fields.FieldByName("max").Int(); max > 0 {
+					c.schema["maxLength"] = max
+				}
+			case "thresholdRule":
+				threshold := fields.FieldByName("threshold").Elem()
+				var n interface{}
+				switch threshold.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+					n = threshold.Int()
+				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+					n = threshold.Uint()
+				case reflect.Float32, reflect.Float64:
+					n = threshold.Float()
+				default:
+					continue
+				}
+				// greaterThan, greaterEqualThan, lessThan, lessEqualThan
+				switch fields.FieldByName("operator").Int() {
+				case 0:
+					c.schema["minimum"], c.schema["exclusiveMinimum"] = n, true
+				case 1:
+					c.schema["minimum"] = n
+				case 2:
+					c.schema["maximum"], c.schema["exclusiveMaximum"] = n, true
+				case 3:
+					c.schema["maximum"] = n
+				}
+			case "inRule":
+				elements := fields.FieldByName("elements")
+				enum := make([]interface{}, 0, elements.Len())
+				for j := 0; j < elements.Len(); j++ {
+					enum = append(enum, scalar(elements.Index(j).Elem()))
+				}
+				c.schema["enum"] = enum
+			default:
+				if message := fields.FieldByName("message"); message.IsValid() && message.Kind() == reflect.String {
+					c.description = message.String()
+				}
+			}
+		}
+	}
+	return constraints
+}
+
+func scalar(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint()
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.Bool:
+		return value.Bool()
+	default:
+		return value.String()
+	}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawType     = reflect.TypeOf(json.RawMessage{})
+	marshaler   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	nullSchemas = map[string]string{
+		"String":  "string",
+		"Bool":    "boolean",
+		"Int":     "integer",
+		"Int64":   "integer",
+		"Uint64":  "integer",
+		"Float64": "number",
+	}
+)
+
+// Schema returns the JSON schema of the values of the type as encoding/json marshals them.
+func Schema(t reflect.Type) map[string]interface{} {
+	return schema(t, make(map[reflect.Type]bool))
+}
+
+func schema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		nullable = true
+		t = t.Elem()
+	}
+	s := typeSchema(t, seen)
+	if nullable {
+		s["nullable"] = true
+	}
+	return s
+}
+
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]interface{}{}
+	case strings.HasSuffix(t.PkgPath(), "extras/null"):
+		if t.Name() == "Time" {
+			return map[string]interface{}{"type": "string", "format": "date-time", "nullable": true}
+		}
+		if typ, ok := nullSchemas[t.Name()]; ok {
+			return map[string]interface{}{"type": typ, "nullable": true}
+		}
+		return map[string]interface{}{"nullable": true}
+	case t.Kind() != reflect.Struct && (t.Implements(marshaler) || reflect.PtrTo(t).Implements(marshaler)):
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		properties := make(map[string]interface{})
+		required := make(map[string]bool)
+		addProperties(t, properties, required, seen)
+		s := map[string]interface{}{"type": "object", "properties": properties}
+		var names []string
+		for name, ok := range required {
+			if ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			s["required"] = names
+		}
+		return s
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// addProperties adds the json properties of the fields of the struct, including those of embedded structs. Properties
+// are required unless their field is omitempty.
+func addProperties(t reflect.Type, properties map[string]interface{}, required map[string]bool,
+	seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		name := options[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addProperties(embedded, properties, required, seen)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schema(field.Type, seen)
+		required[name] = true
+		for _, option := range options[1:] {
+			if option == "omitempty" {
+				required[name] = false
+			}
+		}
+	}
+}
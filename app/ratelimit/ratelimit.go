@@ -0,0 +1,238 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Limit is the rate in tokens per second and the burst size of a token bucket.
+type Limit struct {
+	Rate  float64
+	Burst float64
+}
+
+var (
+	// Enabled turns rate limiting on for the http and grpc servers.
+	Enabled = false
+	// IPLimit is the limit applied per client IP for anonymous requests.
+	IPLimit = Limit{Rate: 10, Burst: 50}
+	// KeyLimit is the limit applied per API key when a valid one is presented.
+	KeyLimit = Limit{Rate: 50, Burst: 250}
+	// TrustProxy uses the first address of the X-Forwarded-For header as the client IP. Only enable it when behind a
+	// proxy that sets the header.
+	TrustProxy = false
+	// Costs holds the base cost in tokens of a request by route. Routes not listed cost 1 and routes with a cost of
+	// 0 are not limited.
+	Costs = map[string]float64{
+		"/metrics":      0,
+		"/healthz":      0,
+		"/readyz":       0,
+		"/search":       1,
+		"/autocomplete": 1,
+		"/graphql":      2,
+		"/feed":         2,
+		"/status":       5,
+		"/status/jobs":  1,
+	}
+	// SizeUnit is the number of requested results that adds one token to the cost of a request.
+	SizeUnit = 100.0
+	// MaxBodyPeek is the size of the beginning of POST bodies read for the number of results they request.
+	MaxBodyPeek int64 = 1 << 16
+
+	store = NewMemoryStore(100000)
+)
+
+// UseRedis shares the token buckets across replicas through the redis instance at the url.
+func UseRedis(url string) error {
+	s, err := NewRedisStore(url)
+	if err != nil {
+		return err
+	}
+	store = s
+	return nil
+}
+
+// ParseCosts parses a list of route costs in the form `/route=2;/route2=0.5` and applies them over the defaults.
+func ParseCosts(costs string) {
+	for _, c := range strings.Split(costs, ";") {
+		parts := strings.SplitN(c, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		cost, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			logrus.Warningf("ignoring invalid rate limit cost %q", c)
+			continue
+		}
+		Costs[strings.TrimSpace(parts[0])] = cost
+	}
+}
+
+// Handler limits the rate of requests per client with token buckets. Each request costs the base cost of its route
+// plus one token per SizeUnit results requested, by the size parameter or in the JSON body of POST requests. Limited
+// requests are answered with 429 and a Retry-After header.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Enabled || r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cost := requestCost(r)
+		if cost <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		allowed, remaining, retryAfter, limit, err := take(auth.FromRequest(r), ClientIP(r), cost)
+		if err != nil {
+			// Fail open, an unavailable store should not take the API down.
+			logrus.Error(err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatFloat(limit.Burst, 'f', -1, 64))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(math.Floor(remaining))))
+		if allowed {
+			h.ServeHTTP(w, r)
+			return
+		}
+		for k, v := range api.ResponseHeaders {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		body, err := api.BuildJSONResponse(api.ResponseInfo{
+			Success: false,
+			Error:   util.PtrToString("rate limit exceeded, retry after " + retryAfter.String()),
+		})
+		if err != nil {
+			logrus.Error(err)
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write(body)
+	})
+}
+
+// Allow takes the cost of a call to the route requesting size results from the bucket of the caller, or of the
+// client ip for anonymous callers, for the servers other than the http api. It returns whether the call is allowed
+// and how long until it would be otherwise.
+func Allow(caller auth.Caller, ip, route string, size int) (bool, time.Duration) {
+	if !Enabled {
+		return true, 0
+	}
+	cost := routeCost(route, size)
+	if cost <= 0 {
+		return true, 0
+	}
+	allowed, _, retryAfter, _, err := take(caller, ip, cost)
+	if err != nil {
+		// Fail open, an unavailable store should not take the API down.
+		logrus.Error(err)
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// take takes cost tokens from the bucket of the caller, or of the client ip for anonymous callers. A cost above the
+// burst of the bucket is lowered to the burst, so that large requests wait for a full bucket instead of never being
+// allowed.
+func take(caller auth.Caller, ip string, cost float64) (bool, float64, time.Duration, Limit, error) {
+	clientType := "ip"
+	key := "ip:" + ip
+	limit := IPLimit
+	if caller.Name != "" {
+		clientType = "key"
+		key = "key:" + caller.Name
+		limit = KeyLimit
+	}
+	cost = math.Min(cost, limit.Burst)
+	allowed, remaining, retryAfter, err := store.Take(key, cost, limit.Rate, limit.Burst)
+	if err == nil && !allowed {
+		metrics.RateLimited.WithLabelValues(clientType).Inc()
+	}
+	return allowed, remaining, retryAfter, limit, err
+}
+
+func requestCost(r *http.Request) float64 {
+	route := strings.TrimRight(r.URL.Path, "/")
+	if routeCost(route, 0) <= 0 {
+		return 0
+	}
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil && r.Method == http.MethodPost {
+		size = bodySize(r)
+	}
+	return routeCost(route, size)
+}
+
+// routeCost returns the base cost of the route plus one token per SizeUnit results requested, 0 for routes that
+// are not limited.
+func routeCost(route string, size int) float64 {
+	cost, ok := Costs[route]
+	if !ok {
+		cost = 1
+	}
+	if cost <= 0 {
+		return 0
+	}
+	if size > 0 && SizeUnit > 0 {
+		cost += math.Floor(float64(size) / SizeUnit)
+	}
+	return cost
+}
+
+// bodySize returns the number of results requested by the JSON body of the request, its size or, as /feed takes,
+// per_channel results for each of its channel_ids. The body is left for the handler to read.
+func bodySize(r *http.Request) int {
+	if r.Body == nil {
+		return 0
+	}
+	peeked, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxBodyPeek))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	if err != nil {
+		return 0
+	}
+	var body struct {
+		Size       int      `json:"size"`
+		PerChannel int      `json:"per_channel"`
+		ChannelIDs []string `json:"channel_ids"`
+	}
+	if json.Unmarshal(peeked, &body) != nil {
+		return 0
+	}
+	if body.PerChannel > 0 {
+		return body.PerChannel * len(body.ChannelIDs)
+	}
+	return body.Size
+}
+
+// ClientIP returns the ip of the client of the request, from the X-Forwarded-For header if TrustProxy is set.
+func ClientIP(r *http.Request) string {
+	if TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/go-redis/redis"
+)
+
+// takeScript refills and takes from a bucket atomically so that replicas sharing the redis instance share limits.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	wait = math.ceil((cost - tokens) / rate * 1000)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens), wait}
+`)
+
+type redisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a store backed by redis at the url, such as redis://localhost:6379/0.
+func NewRedisStore(url string) (Store, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	client := redis.NewClient(opts)
+	err = client.Ping().Err()
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	return &redisStore{client: client}, nil
+}
+
+func (s *redisStore) Take(key string, cost, rate, burst float64) (bool, float64, time.Duration, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	res, err := takeScript.Run(s.client, []string{"lighthouse:ratelimit:" + key}, rate, burst, cost, now).Result()
+	if err != nil {
+		return false, 0, 0, errors.Err(err)
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return false, 0, 0, errors.Err("unexpected rate limit script result %v", res)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, _ := values[1].(string)
+	remaining, _ := strconv.ParseFloat(tokens, 64)
+	wait, _ := values[2].(int64)
+	return allowed == 1, remaining, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/karlseguin/ccache"
+)
+
+// Store keeps the token buckets of the clients being rate limited.
+type Store interface {
+	// Take removes cost tokens from the bucket for key, which refills at rate tokens per second up to burst tokens. It
+	// returns whether the tokens were available, the tokens remaining and how long until enough tokens would be.
+	Take(key string, cost, rate, burst float64) (allowed bool, remaining float64, retryAfter time.Duration, err error)
+}
+
+type bucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// memoryStore keeps buckets in process. Limits are per replica.
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets *ccache.Cache
+}
+
+// NewMemoryStore creates a store that keeps up to maxClients buckets in memory.
+func NewMemoryStore(maxClients int64) Store {
+	return &memoryStore{buckets: ccache.New(ccache.Configure().MaxSize(maxClients))}
+}
+
+func (m *memoryStore) Take(key string, cost, rate, burst float64) (bool, float64, time.Duration, error) {
+	idle := time.Duration(burst/rate*float64(time.Second)) + time.Second
+	m.mu.Lock()
+	item := m.buckets.Get(key)
+	if item == nil || item.Expired() {
+		m.buckets.Set(key, &bucket{tokens: burst, last: time.Now()}, idle)
+		item = m.buckets.Get(key)
+	} else {
+		item.Extend(idle)
+	}
+	m.mu.Unlock()
+
+	b := item.Value().(*bucket)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < cost {
+		wait := time.Duration((cost - b.tokens) / rate * float64(time.Second))
+		return false, b.tokens, wait, nil
+	}
+	b.tokens -= cost
+	return true, b.tokens, 0, nil
+}
@@ -0,0 +1,315 @@
+package reindex
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/jobs/chainquery"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+var (
+	// CatchUpMargin is subtracted from the time catch ups start from, for the clock of the claim source not matching
+	// ours.
+	CatchUpMargin = 5 * time.Minute
+	// SampleSize is the number of the latest claims of the serving index checked to be in the new one.
+	SampleSize = 100
+)
+
+// Options configures a reindex.
+type Options struct {
+	// FromES copies the serving index with the _reindex API instead of reading every claim from the claim source.
+	FromES bool
+	// Tolerance is the fraction of the claims of the serving index, and of the sampled claims, the new index may lack.
+	Tolerance float64
+	// DropLegacy allows deleting the claims index created before the aliases when the read alias replaces it, it
+	// cannot be rolled back to.
+	DropLegacy bool
+	// KeepFailed keeps the new index when the reindex fails, to inspect it.
+	KeepFailed bool
+	// Since replaces the time of the last completed claim sync read from the sync state, for reindexes run where the
+	// claim sync does not run.
+	Since time.Time
+}
+
+// Run builds the next version of the claims index and moves the aliases to it without interrupting searches or the
+// claim sync. The index is filled from the claim source, or copied from the serving index, and caught up on the
+// claims modified meanwhile. The write alias is then moved to it and the index caught up again, so that it misses no
+// write. It is validated against the serving index before the read alias is moved, otherwise the write alias is
+// moved back and the new index deleted. The previous index is kept for Rollback. It returns the name of the new index.
+func Run(ctx context.Context, opts Options) (string, error) {
+	// Fail before building anything if the index could not be caught up.
+	_, err := lastSync(opts.Since)
+	if err != nil {
+		return "", err
+	}
+	read, write, err := es.ClaimsAliases(ctx)
+	if err != nil {
+		return "", err
+	}
+	if read == "" || write != read {
+		return "", errors.Err("the read and write aliases point to %q and %q, run reindex --rollback to recover from an interrupted reindex", read, write)
+	}
+	if read == index.Claims && !opts.DropLegacy {
+		return "", errors.Err("%s was created before the aliases and is deleted when the read alias replaces it, rerun with --drop-legacy to proceed", read)
+	}
+	versions, err := es.ClaimsVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1] + 1
+	}
+	name := index.Name(next)
+	err = es.CreateClaimsIndex(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	logrus.Infof("reindex: building %s to replace %s", name, read)
+	// The cleanup after a failure is not interrupted with the reindex.
+	cleanup := context.Background()
+	err = build(ctx, opts, read, name)
+	if err != nil {
+		return "", fail(cleanup, opts, name, err)
+	}
+	logrus.Infof("reindex: moving %s to %s", index.ClaimsWrite, name)
+	start := time.Now()
+	err = es.MoveAliases(ctx, read, name, index.ClaimsWrite)
+	if err != nil {
+		return "", fail(cleanup, opts, name, err)
+	}
+	err = catchUp(ctx, name, start, opts.Since)
+	if err == nil {
+		err = validate(ctx, opts, read, name)
+	}
+	if err != nil {
+		return "", fail(cleanup, opts, name, abort(cleanup, read, name, opts.Since, err))
+	}
+	logrus.Infof("reindex: moving %s to %s", index.Claims, name)
+	err = es.MoveAliases(ctx, read, name, index.Claims)
+	if err != nil {
+		return "", fail(cleanup, opts, name, abort(cleanup, read, name, opts.Since, err))
+	}
+	logrus.Infof("reindex: %s serves the claims, %s is kept for rollback", name, read)
+	return name, nil
+}
+
+// Rollback moves the aliases back to the previous version of the claims index, and catches it up on the claims
+// modified since it stopped being written to. If a reindex was interrupted after moving the write alias, the write
+// alias is moved back to the serving index instead. since replaces the time of the last completed claim sync like
+// Options.Since. It returns the name of the index serving the claims.
+func Rollback(ctx context.Context, since time.Time) (string, error) {
+	_, err := lastSync(since)
+	if err != nil {
+		return "", err
+	}
+	read, write, err := es.ClaimsAliases(ctx)
+	if err != nil {
+		return "", err
+	}
+	if read == "" {
+		return "", errors.Err("there is no claims index")
+	}
+	if write != "" && write != read {
+		return read, abort(ctx, read, write, since, nil)
+	}
+	current, ok := index.Version(read)
+	if !ok {
+		return "", errors.Err("%s is not a versioned claims index, there is nothing to roll back to", read)
+	}
+	versions, err := es.ClaimsVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+	previous := ""
+	for _, version := range versions {
+		if version < current {
+			previous = index.Name(version)
+		}
+	}
+	if previous == "" {
+		return "", errors.Err("there is no claims index before %s", read)
+	}
+	created, err := creationDate(ctx, read)
+	if err != nil {
+		return "", err
+	}
+	logrus.Infof("reindex: rolling back from %s to %s", read, previous)
+	err = es.MoveAliases(ctx, read, previous, index.Claims, index.ClaimsWrite)
+	if err != nil {
+		return "", err
+	}
+	return previous, catchUp(ctx, previous, created, since)
+}
+
+// build fills the new index and catches it up on the claims modified while it was filled.
+func build(ctx context.Context, opts Options, read, name string) error {
+	start := time.Now()
+	if opts.FromES {
+		res, err := es.Client.Reindex().SourceIndex(read).DestinationIndex(name).WaitForCompletion(true).Do(ctx)
+		if err != nil {
+			return errors.Prefix("copying "+read, err)
+		}
+		if len(res.Failures) > 0 {
+			return errors.Err("copying %s failed for %d claims", read, len(res.Failures))
+		}
+		logrus.Infof("reindex: copied %d claims from %s", res.Created, read)
+	} else {
+		count, err := chainquery.Fill(ctx, name, time.Time{})
+		if err != nil {
+			return err
+		}
+		logrus.Infof("reindex: indexed %d claims from the claim source", count)
+	}
+	return catchUp(ctx, name, start, opts.Since)
+}
+
+// catchUp indexes the claims modified since the time given into the index. It starts from the last completed claim
+// sync if it is earlier, as the claim sync writes the claims modified since then, or from override if it is set.
+func catchUp(ctx context.Context, name string, since, override time.Time) error {
+	last, err := lastSync(override)
+	if err != nil {
+		return err
+	}
+	if last.Before(since) {
+		since = last
+	}
+	since = since.Add(-CatchUpMargin)
+	count, err := chainquery.Fill(ctx, name, since)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("reindex: caught up %s on %d claims modified since %s", name, count, since.Format(time.RFC3339))
+	return nil
+}
+
+// validate checks that the new index lacks at most the tolerated fraction of the claims of the serving index, and
+// of the latest claims it serves.
+func validate(ctx context.Context, opts Options, read, name string) error {
+	_, err := es.Client.Refresh(read, name).Do(ctx)
+	if err != nil {
+		return errors.Err(err)
+	}
+	served, err := es.Client.Count(read).Do(ctx)
+	if err != nil {
+		return errors.Err(err)
+	}
+	built, err := es.Client.Count(name).Do(ctx)
+	if err != nil {
+		return errors.Err(err)
+	}
+	if float64(built) < float64(served)*(1-opts.Tolerance) {
+		return errors.Err("%s has %d claims, %s has %d", name, built, read, served)
+	}
+	logrus.Infof("reindex: %s has %d claims, %s has %d", name, built, read, served)
+	latest, err := es.Client.Search(read).Sort("transaction_time", false).FetchSource(false).Size(SampleSize).Do(ctx)
+	if err != nil {
+		return errors.Err(err)
+	}
+	if len(latest.Hits.Hits) == 0 {
+		return nil
+	}
+	service := es.Client.MultiGet()
+	for _, hit := range latest.Hits.Hits {
+		service.Add(elastic.NewMultiGetItem().Index(name).Type(index.ClaimType).Id(hit.Id).
+			FetchSource(elastic.NewFetchSourceContext(false)))
+	}
+	res, err := service.Do(ctx)
+	if err != nil {
+		return errors.Err(err)
+	}
+	missing := 0
+	for _, doc := range res.Docs {
+		if doc == nil || !doc.Found {
+			missing++
+		}
+	}
+	if float64(missing) > float64(len(latest.Hits.Hits))*opts.Tolerance {
+		return errors.Err("%s lacks %d of the latest %d claims of %s", name, missing, len(latest.Hits.Hits), read)
+	}
+	return nil
+}
+
+// abort moves the write alias back to the serving index and catches it up on the claims written to the new index
+// meanwhile, returning err.
+func abort(ctx context.Context, read, name string, override time.Time, err error) error {
+	logrus.Warnf("reindex: moving %s back to %s", index.ClaimsWrite, read)
+	created, dateErr := creationDate(ctx, name)
+	if dateErr != nil {
+		return errors.Prefix("rolling back after "+errString(err), dateErr)
+	}
+	moveErr := es.MoveAliases(ctx, name, read, index.ClaimsWrite)
+	if moveErr != nil {
+		return errors.Prefix("rolling back after "+errString(err), moveErr)
+	}
+	catchUpErr := catchUp(ctx, read, created, override)
+	if catchUpErr != nil {
+		return errors.Prefix("catching up "+read+" after "+errString(err), catchUpErr)
+	}
+	return err
+}
+
+// fail deletes the new index unless it is kept or still written to, and returns err.
+func fail(ctx context.Context, opts Options, name string, err error) error {
+	_, write, aliasErr := es.ClaimsAliases(ctx)
+	if opts.KeepFailed || aliasErr != nil || write == name {
+		logrus.Warnf("reindex: keeping %s", name)
+		return err
+	}
+	_, deleteErr := es.Client.DeleteIndex(name).Do(ctx)
+	if deleteErr != nil {
+		logrus.Error(errors.Prefix("deleting "+name, deleteErr))
+	}
+	return err
+}
+
+// creationDate returns when the index was created.
+func creationDate(ctx context.Context, name string) (time.Time, error) {
+	res, err := es.Client.IndexGetSettings(name).Name("index.creation_date").Do(ctx)
+	if err != nil {
+		return time.Time{}, errors.Err(err)
+	}
+	settings, ok := res[name]
+	if !ok {
+		return time.Time{}, errors.Err("no settings returned for %s", name)
+	}
+	indexSettings, _ := settings.Settings["index"].(map[string]interface{})
+	created, _ := indexSettings["creation_date"].(string)
+	ms, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Err("invalid creation date %q of %s", created, name)
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+// lastSync returns override if it is set, or the time of the last completed claim sync. It fails if the sync state
+// records none, as catching up from the zero time would silently refill the whole index, which happens when the
+// reindex does not run where the claim sync saves its state.
+func lastSync(override time.Time) (time.Time, error) {
+	if !override.IsZero() {
+		return override, nil
+	}
+	last, err := chainquery.LastSyncTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if last.IsZero() {
+		return time.Time{}, errors.Err("the claim sync state in %s records no completed sync, run the reindex where the claim sync runs or pass --since", chainquery.SyncStateDir)
+	}
+	return last, nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "an interrupted reindex"
+	}
+	return err.Error()
+}
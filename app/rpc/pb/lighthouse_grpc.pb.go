@@ -0,0 +1,285 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// LighthouseClient is the client API for Lighthouse service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LighthouseClient interface {
+	// Search returns the claims matching the search, built the same way as for /search.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// ExportSearch streams every claim matching the search instead of a single page, for exports larger than Search
+	// can return. The size of the request caps the number of claims streamed. It requires an api key.
+	ExportSearch(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (Lighthouse_ExportSearchClient, error)
+	// AutoComplete returns the names of the claims completing the query, as /autocomplete does.
+	AutoComplete(ctx context.Context, in *AutoCompleteRequest, opts ...grpc.CallOption) (*AutoCompleteResponse, error)
+	// GetClaim returns the claim with the claim id, or the NOT_FOUND code if it is not indexed.
+	GetClaim(ctx context.Context, in *GetClaimRequest, opts ...grpc.CallOption) (*Claim, error)
+	// Status returns the version of lighthouse and the state of elasticsearch.
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+}
+
+type lighthouseClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLighthouseClient(cc grpc.ClientConnInterface) LighthouseClient {
+	return &lighthouseClient{cc}
+}
+
+func (c *lighthouseClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/lighthouse.Lighthouse/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lighthouseClient) ExportSearch(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (Lighthouse_ExportSearchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Lighthouse_ServiceDesc.Streams[0], "/lighthouse.Lighthouse/ExportSearch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lighthouseExportSearchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Lighthouse_ExportSearchClient interface {
+	Recv() (*Claim, error)
+	grpc.ClientStream
+}
+
+type lighthouseExportSearchClient struct {
+	grpc.ClientStream
+}
+
+func (x *lighthouseExportSearchClient) Recv() (*Claim, error) {
+	m := new(Claim)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *lighthouseClient) AutoComplete(ctx context.Context, in *AutoCompleteRequest, opts ...grpc.CallOption) (*AutoCompleteResponse, error) {
+	out := new(AutoCompleteResponse)
+	err := c.cc.Invoke(ctx, "/lighthouse.Lighthouse/AutoComplete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lighthouseClient) GetClaim(ctx context.Context, in *GetClaimRequest, opts ...grpc.CallOption) (*Claim, error) {
+	out := new(Claim)
+	err := c.cc.Invoke(ctx, "/lighthouse.Lighthouse/GetClaim", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lighthouseClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/lighthouse.Lighthouse/Status", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LighthouseServer is the server API for Lighthouse service.
+// All implementations must embed UnimplementedLighthouseServer
+// for forward compatibility
+type LighthouseServer interface {
+	// Search returns the claims matching the search, built the same way as for /search.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// ExportSearch streams every claim matching the search instead of a single page, for exports larger than Search
+	// can return. The size of the request caps the number of claims streamed. It requires an api key.
+	ExportSearch(*SearchRequest, Lighthouse_ExportSearchServer) error
+	// AutoComplete returns the names of the claims completing the query, as /autocomplete does.
+	AutoComplete(context.Context, *AutoCompleteRequest) (*AutoCompleteResponse, error)
+	// GetClaim returns the claim with the claim id, or the NOT_FOUND code if it is not indexed.
+	GetClaim(context.Context, *GetClaimRequest) (*Claim, error)
+	// Status returns the version of lighthouse and the state of elasticsearch.
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	mustEmbedUnimplementedLighthouseServer()
+}
+
+// UnimplementedLighthouseServer must be embedded to have forward compatible implementations.
+type UnimplementedLighthouseServer struct {
+}
+
+func (UnimplementedLighthouseServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedLighthouseServer) ExportSearch(*SearchRequest, Lighthouse_ExportSearchServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportSearch not implemented")
+}
+func (UnimplementedLighthouseServer) AutoComplete(context.Context, *AutoCompleteRequest) (*AutoCompleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AutoComplete not implemented")
+}
+func (UnimplementedLighthouseServer) GetClaim(context.Context, *GetClaimRequest) (*Claim, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClaim not implemented")
+}
+func (UnimplementedLighthouseServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedLighthouseServer) mustEmbedUnimplementedLighthouseServer() {}
+
+// UnsafeLighthouseServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LighthouseServer will
+// result in compilation errors.
+type UnsafeLighthouseServer interface {
+	mustEmbedUnimplementedLighthouseServer()
+}
+
+func RegisterLighthouseServer(s grpc.ServiceRegistrar, srv LighthouseServer) {
+	s.RegisterService(&Lighthouse_ServiceDesc, srv)
+}
+
+func _Lighthouse_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LighthouseServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lighthouse.Lighthouse/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LighthouseServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lighthouse_ExportSearch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LighthouseServer).ExportSearch(m, &lighthouseExportSearchServer{stream})
+}
+
+type Lighthouse_ExportSearchServer interface {
+	Send(*Claim) error
+	grpc.ServerStream
+}
+
+type lighthouseExportSearchServer struct {
+	grpc.ServerStream
+}
+
+func (x *lighthouseExportSearchServer) Send(m *Claim) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Lighthouse_AutoComplete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AutoCompleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LighthouseServer).AutoComplete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lighthouse.Lighthouse/AutoComplete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LighthouseServer).AutoComplete(ctx, req.(*AutoCompleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lighthouse_GetClaim_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClaimRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LighthouseServer).GetClaim(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lighthouse.Lighthouse/GetClaim",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LighthouseServer).GetClaim(ctx, req.(*GetClaimRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lighthouse_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LighthouseServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lighthouse.Lighthouse/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LighthouseServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Lighthouse_ServiceDesc is the grpc.ServiceDesc for Lighthouse service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Lighthouse_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lighthouse.Lighthouse",
+	HandlerType: (*LighthouseServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _Lighthouse_Search_Handler,
+		},
+		{
+			MethodName: "AutoComplete",
+			Handler:    _Lighthouse_AutoComplete_Handler,
+		},
+		{
+			MethodName: "GetClaim",
+			Handler:    _Lighthouse_GetClaim_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Lighthouse_Status_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportSearch",
+			Handler:       _Lighthouse_ExportSearch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "lighthouse.proto",
+}
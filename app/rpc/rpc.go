@@ -0,0 +1,432 @@
+package rpc
+
+//go:generate protoc -I pb --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative lighthouse.proto
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/actions"
+	"github.com/lbryio/lighthouse/app/actions/search"
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/breaker"
+	"github.com/lbryio/lighthouse/app/cache"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+	"github.com/lbryio/lighthouse/app/ratelimit"
+	"github.com/lbryio/lighthouse/app/rpc/pb"
+	"github.com/lbryio/lighthouse/app/timeout"
+	"github.com/lbryio/lighthouse/app/tracing"
+	"github.com/lbryio/lighthouse/meta"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// apiKeyMetadata is the metadata key callers present their api key in.
+const apiKeyMetadata = "x-api-key"
+
+// clusterCache holds the cluster health and claim count returned by Status, so that frequent status calls do not
+// each query elasticsearch.
+var clusterCache = cache.New("grpc_status", 10, 5*time.Second, 10*time.Second)
+
+// cluster is the part of the status read from elasticsearch.
+type cluster struct {
+	health string
+	count  int64
+}
+
+// routes maps the methods of the service to the http routes whose deadline and rate limit cost they share.
+var routes = map[string]string{
+	"Search":       "/search",
+	"AutoComplete": "/autocomplete",
+	"GetClaim":     "/claim",
+	"Status":       "/status",
+}
+
+type server struct {
+	pb.UnimplementedLighthouseServer
+}
+
+// NewServer creates the grpc server of the Lighthouse service. Calls are authenticated with the api key in their
+// metadata, rate limited like the http route they mirror and given its deadline. ExportSearch is rate limited like
+// /search for the size it requests and only bound by the deadline of the caller.
+func NewServer() *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(unaryInterceptor), grpc.StreamInterceptor(streamInterceptor))
+	pb.RegisterLighthouseServer(s, &server{})
+	return s
+}
+
+func (s *server) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	ctx, incomplete := timeout.Track(ctx)
+	ctx, degraded := breaker.Track(ctx)
+	rsp := search.Search(request(ctx, "/search", searchParams(req)))
+	if rsp.Error != nil {
+		return nil, statusError(rsp)
+	}
+	hits, ok := rsp.Data.([]map[string]interface{})
+	if !ok {
+		return nil, status.Error(codes.Internal, "unexpected search results")
+	}
+	res := &pb.SearchResponse{Claims: make([]*pb.Claim, 0, len(hits)), Degraded: degraded()}
+	for _, hit := range hits {
+		res.Claims = append(res.Claims, newClaim(hit))
+	}
+	res.Partial, res.TimedOut = incomplete()
+	return res, nil
+}
+
+func (s *server) ExportSearch(req *pb.SearchRequest, stream pb.Lighthouse_ExportSearchServer) error {
+	r := request(stream.Context(), "/search", searchParams(req))
+	err := search.Export(r, func(claim map[string]interface{}) error {
+		return stream.Send(newClaim(claim))
+	})
+	if err != nil {
+		return statusError(api.Response{Error: err})
+	}
+	return nil
+}
+
+func (s *server) AutoComplete(ctx context.Context, req *pb.AutoCompleteRequest) (*pb.AutoCompleteResponse, error) {
+	params := url.Values{"s": {req.S}}
+	if req.Size != nil {
+		params.Set("size", strconv.Itoa(int(*req.Size)))
+	}
+	if req.From != nil {
+		params.Set("from", strconv.Itoa(int(*req.From)))
+	}
+	if req.Nsfw != nil {
+		params.Set("nsfw", strconv.FormatBool(*req.Nsfw))
+	}
+	ctx, incomplete := timeout.Track(ctx)
+	ctx, degraded := breaker.Track(ctx)
+	rsp := actions.AutoComplete(request(ctx, "/autocomplete", params))
+	if rsp.Error != nil {
+		return nil, statusError(rsp)
+	}
+	names, _ := rsp.Data.([]string)
+	res := &pb.AutoCompleteResponse{Names: names, Degraded: degraded()}
+	res.Partial, res.TimedOut = incomplete()
+	return res, nil
+}
+
+func (s *server) GetClaim(ctx context.Context, req *pb.GetClaimRequest) (*pb.Claim, error) {
+	if req.ClaimId == "" {
+		return nil, status.Error(codes.InvalidArgument, "claim_id is required")
+	}
+	claim, err := es.NewLoader(ctx).Load(req.ClaimId)()
+	if err != nil {
+		return nil, statusError(api.Response{Error: timeout.Error(err)})
+	}
+	if claim == nil {
+		return nil, status.Errorf(codes.NotFound, "claim %s not found", req.ClaimId)
+	}
+	return newClaim(claim), nil
+}
+
+func (s *server) Status(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+	b := es.Breaker.Status()
+	res := &pb.StatusResponse{
+		Version:         meta.GetVersion(),
+		SemanticVersion: meta.GetSemVersion(),
+		VersionLong:     meta.GetVersionLong(),
+		Breaker: &pb.BreakerStatus{
+			Name:     b.Name,
+			State:    b.State,
+			Calls:    int32(b.Calls),
+			Failures: int32(b.Failures),
+		},
+	}
+	if !b.OpenedAt.IsZero() {
+		res.Breaker.OpenedAt = timestamppb.New(b.OpenedAt)
+	}
+	value, _, err := clusterCache.Fetch(ctx, "cluster", func(ctx context.Context) (interface{}, error) {
+		health, err := es.Client.CatHealth().Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		count, err := es.Client.CatCount().Index(index.Claims).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c := cluster{}
+		if len(health) > 0 {
+			c.health = health[0].Status
+		}
+		if len(count) > 0 {
+			c.count = int64(count[0].Count)
+		}
+		return c, nil
+	})
+	if err != nil {
+		return nil, statusError(api.Response{Error: timeout.Error(err)})
+	}
+	c := value.(cluster)
+	res.ClusterHealth = c.health
+	res.ClaimCount = c.count
+	return res, nil
+}
+
+// request returns a request for the http route with the parameters in the context of the call, so that calls go
+// through the same validation, limits and caches as the http api.
+func request(ctx context.Context, route string, params url.Values) *http.Request {
+	r := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: route, RawQuery: params.Encode()},
+		Header: http.Header{}, Form: params, Body: http.NoBody}
+	return r.WithContext(ctx)
+}
+
+func searchParams(req *pb.SearchRequest) url.Values {
+	params := url.Values{"s": {req.S}}
+	optional := map[string]*string{
+		"channel":     req.Channel,
+		"channel_id":  req.ChannelId,
+		"related_to":  req.RelatedTo,
+		"sort_by":     req.SortBy,
+		"include":     req.Include,
+		"contentType": req.ContentType,
+		"mediaType":   req.MediaType,
+		"claimType":   req.ClaimType,
+		"claim_id":    req.ClaimId,
+	}
+	for name, value := range optional {
+		if value != nil {
+			params.Set(name, *value)
+		}
+	}
+	if req.Size != nil {
+		params.Set("size", strconv.Itoa(int(*req.Size)))
+	}
+	if req.From != nil {
+		params.Set("from", strconv.Itoa(int(*req.From)))
+	}
+	if req.Nsfw != nil {
+		params.Set("nsfw", strconv.FormatBool(*req.Nsfw))
+	}
+	if req.FreeOnly != nil {
+		params.Set("free_only", strconv.FormatBool(*req.FreeOnly))
+	}
+	if req.Resolve {
+		params.Set("resolve", "true")
+	}
+	return params
+}
+
+// newClaim converts the source of a claim document, numbers are decoded from json as float64.
+func newClaim(source map[string]interface{}) *pb.Claim {
+	str := func(field string) string {
+		s, _ := source[field].(string)
+		return s
+	}
+	num := func(field string) float64 {
+		n, _ := source[field].(float64)
+		return n
+	}
+	boolean := func(field string) bool {
+		b, _ := source[field].(bool)
+		return b
+	}
+	timestamp := func(field string) *timestamppb.Timestamp {
+		t, err := time.Parse(time.RFC3339, str(field))
+		if err != nil || t.IsZero() {
+			return nil
+		}
+		return timestamppb.New(t)
+	}
+	claim := &pb.Claim{
+		ClaimId:         str("claimId"),
+		Name:            str("name"),
+		Title:           str("title"),
+		Description:     str("description"),
+		ThumbnailUrl:    str("thumbnail_url"),
+		ReleaseTime:     timestamp("release_time"),
+		TransactionTime: timestamp("transaction_time"),
+		ContentType:     str("content_type"),
+		ClaimType:       str("claim_type"),
+		Duration:        uint64(num("duration")),
+		Nsfw:            boolean("nsfw"),
+		Fee:             num("fee"),
+		ViewCount:       uint64(num("view_cnt")),
+		SubCount:        uint64(num("sub_cnt")),
+		ClaimCount:      uint64(num("claim_cnt")),
+		EffectiveAmount: uint64(num("effective_amount")),
+		CertValid:       boolean("cert_valid"),
+		Channel:         str("channel"),
+		ChannelClaimId:  str("channel_claim_id"),
+	}
+	if tags, ok := source["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if t, ok := tag.(string); ok {
+				claim.Tags = append(claim.Tags, t)
+			}
+		}
+	}
+	return claim
+}
+
+// statusError converts the error of an api response to a grpc status with the code matching its http status.
+func statusError(rsp api.Response) error {
+	httpStatus := rsp.Status
+	if httpStatus == 0 {
+		httpStatus = http.StatusInternalServerError
+		if statusErr, ok := errors.Unwrap(rsp.Error).(api.StatusError); ok {
+			httpStatus = statusErr.Status
+		}
+	}
+	code := codes.Internal
+	switch httpStatus {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, rsp.Error.Error())
+}
+
+// authenticate returns the context of the call with its caller, or the unauthenticated code if it presented an
+// unknown api key.
+func authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(apiKeyMetadata)
+	if len(keys) == 0 || keys[0] == "" {
+		return ctx, nil
+	}
+	caller, ok := auth.Lookup(keys[0])
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	return auth.WithCaller(ctx, caller), nil
+}
+
+// observe records the metrics and span of a call, turning panics into the internal code.
+func observe(ctx context.Context, fullMethod string, call func(ctx context.Context) error) (err error) {
+	method := path.Base(fullMethod)
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "gRPC "+method)
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Errorf("grpc %s panicked: %v", method, r)
+			err = status.Error(codes.Internal, "internal error")
+		}
+		tracing.End(span, err)
+		metrics.GRPCRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+		metrics.GRPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	}()
+	ctx, err = authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	return call(ctx)
+}
+
+// limit takes the cost of a call to the route requesting size results from the rate limit of its caller, keyed by
+// api key or peer address, returning the resource exhausted code if it is over the limit.
+func limit(ctx context.Context, route string, size int) error {
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	allowed, retryAfter := ratelimit.Allow(auth.FromContext(ctx), ip, route, size)
+	if !allowed {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded, retry after "+retryAfter.String())
+	}
+	return nil
+}
+
+// requestSize returns the number of results the request of a call asks for.
+func requestSize(req interface{}) int {
+	switch req := req.(type) {
+	case *pb.SearchRequest:
+		if req.Size != nil {
+			return int(*req.Size)
+		}
+	case *pb.AutoCompleteRequest:
+		if req.Size != nil {
+			return int(*req.Size)
+		}
+	}
+	return 0
+}
+
+func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var res interface{}
+	err := observe(ctx, info.FullMethod, func(ctx context.Context) error {
+		route, ok := routes[path.Base(info.FullMethod)]
+		if !ok {
+			route = info.FullMethod
+		}
+		err := limit(ctx, route, requestSize(req))
+		if err != nil {
+			return err
+		}
+		d, configured := timeout.Routes[route]
+		if !ok || !configured {
+			d = timeout.Default
+		}
+		if d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		res, err = handler(ctx, req)
+		return err
+	})
+	return res, err
+}
+
+func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return observe(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	})
+}
+
+// serverStream replaces the context of a stream with the one carrying its caller, and rate limits it like a search
+// of the size of its first request.
+type serverStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	limited bool
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil || s.limited {
+		return err
+	}
+	s.limited = true
+	size := search.MaxExport
+	if requested := requestSize(m); requested > 0 && requested < size {
+		size = requested
+	}
+	return limit(s.ctx, "/search", size)
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,361 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/internal/metrics"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+var (
+	// BufferSize is the number of events kept in memory for reconnecting clients to catch up from.
+	BufferSize = 10000
+	// MaxPending is the number of events that can be queued for a connection before it is closed as too slow. The
+	// client can reconnect and catch up from the buffer.
+	MaxPending = 1000
+	// MaxSubscribers is the number of streams that can be open at once.
+	MaxSubscribers = 1000
+	// MaxPerClient is the number of streams a single api key or client ip can have open at once, 0 for no limit.
+	MaxPerClient = 10
+
+	// ErrClientLimit is returned when a client already has MaxPerClient streams open.
+	ErrClientLimit = errors.Base("too many open streams for this client")
+
+	// Fields are the fields of the claims sent in events.
+	Fields = []string{"name", "claimId", "title", "channel", "channel_claim_id", "thumbnail_url", "release_time",
+		"content_type", "claim_type", "tags", "nsfw", "duration"}
+
+	// epoch identifies this process in event ids, ids from another process cannot be caught up from.
+	epoch = strconv.FormatInt(time.Now().Unix(), 36)
+	hub   = &broker{subscribers: make(map[*Subscriber]bool), clients: make(map[string]int)}
+)
+
+// Event is a claim that was indexed, as sent to the streams.
+type Event struct {
+	Seq   uint64
+	Claim map[string]interface{}
+}
+
+// ID returns the id of the event as sent in the Last-Event-ID header.
+func (e Event) ID() string {
+	return epoch + "-" + strconv.FormatUint(e.Seq, 10)
+}
+
+// ParseID returns the sequence number of the event id, and false if it was not issued by this process.
+func ParseID(id string) (uint64, bool) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 || parts[0] != epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	return seq, err == nil
+}
+
+// Filter selects the events sent to a subscriber. Empty fields match every claim.
+type Filter struct {
+	ChannelIDs map[string]bool
+	Tags       map[string]bool
+	ClaimType  string
+	// Query is evaluated against elasticsearch for each batch of events passing the other filters.
+	Query elastic.Query
+}
+
+// matches returns whether the claim passes the filters that do not need elasticsearch.
+func (f Filter) matches(claim map[string]interface{}) bool {
+	if len(f.ChannelIDs) > 0 {
+		channelID, _ := claim["channel_claim_id"].(string)
+		if !f.ChannelIDs[channelID] {
+			return false
+		}
+	}
+	if f.ClaimType != "" {
+		if claimType, _ := claim["claim_type"].(string); claimType != f.ClaimType {
+			return false
+		}
+	}
+	if len(f.Tags) > 0 {
+		tags, _ := claim["tags"].([]interface{})
+		for _, tag := range tags {
+			if t, ok := tag.(string); ok && f.Tags[t] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Subscriber receives the events matching its filter.
+type Subscriber struct {
+	filter  Filter
+	client  string
+	queue   chan []Event
+	mu      sync.Mutex
+	pending int
+	// Closed is closed when the subscriber is removed, because it fell behind or the server is shutting down.
+	Closed chan struct{}
+	// Overflowed is set when the subscriber was removed for falling behind.
+	Overflowed bool
+}
+
+// Next returns the channel the batches of events matching the filter are queued on.
+func (s *Subscriber) Next() <-chan []Event {
+	return s.queue
+}
+
+// Done records that a batch returned by Next was written.
+func (s *Subscriber) Done(batch []Event) {
+	s.mu.Lock()
+	s.pending -= len(batch)
+	s.mu.Unlock()
+}
+
+// Select returns the events of the batch matching the query of the filter, if any.
+func (s *Subscriber) Select(ctx context.Context, batch []Event) ([]Event, error) {
+	if s.filter.Query == nil || len(batch) == 0 {
+		return batch, nil
+	}
+	ids := make([]interface{}, len(batch))
+	for i, e := range batch {
+		ids[i], _ = e.Claim["claimId"].(string)
+	}
+	res, err := es.Client.Search(index.Claims).
+		Query(elastic.NewBoolQuery().Must(s.filter.Query).Filter(elastic.NewTermsQuery("claimId.keyword", ids...))).
+		FetchSource(false).
+		Size(len(ids)).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	matched := make(map[string]bool, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		matched[hit.Id] = true
+	}
+	var selected []Event
+	for i, e := range batch {
+		if matched[ids[i].(string)] {
+			selected = append(selected, e)
+		}
+	}
+	return selected, nil
+}
+
+// Subscribe registers a subscriber of the client, its api key or ip, for the events matching the filter, and returns
+// the buffered events after the event id given by a reconnecting client. It returns false for caughtUp if events
+// after the id are no longer buffered or the id was issued by another process, in which case the whole buffer is
+// returned. It returns ErrClientLimit if the client has MaxPerClient streams open, and an error if MaxSubscribers
+// are already subscribed or the server is shutting down.
+func Subscribe(filter Filter, client, lastEventID string) (s *Subscriber, backlog []Event, caughtUp bool, err error) {
+	return hub.subscribe(filter, client, lastEventID)
+}
+
+// Unsubscribe removes the subscriber.
+func Unsubscribe(s *Subscriber) {
+	hub.remove(s, false)
+}
+
+// Publish fetches the claims that were just indexed, adds them to the buffer and queues them for the subscribers
+// whose filters they match.
+func Publish(claimIDs []string) {
+	if len(claimIDs) == 0 || es.Client == nil {
+		return
+	}
+	ctx := context.Background()
+	claims, err := fetch(ctx, claimIDs)
+	if err != nil {
+		logrus.Error(errors.Prefix("claim stream", err))
+		return
+	}
+	if hub.hasQueries() {
+		// Make the claims searchable for the subscribers filtering them with a query.
+		_, err = es.Client.Refresh(index.Claims).Do(ctx)
+		if err != nil {
+			logrus.Error(errors.Prefix("claim stream", err))
+		}
+	}
+	hub.publish(claims)
+}
+
+// Shutdown closes every stream and refuses new ones, so that the API server can drain.
+func Shutdown() {
+	hub.shutdown()
+}
+
+func fetch(ctx context.Context, claimIDs []string) ([]map[string]interface{}, error) {
+	source := elastic.NewFetchSourceContext(true).Include(Fields...)
+	var claims []map[string]interface{}
+	for start := 0; start < len(claimIDs); start += 1000 {
+		end := start + 1000
+		if end > len(claimIDs) {
+			end = len(claimIDs)
+		}
+		service := es.Client.MultiGet()
+		for _, id := range claimIDs[start:end] {
+			service.Add(elastic.NewMultiGetItem().Index(index.Claims).Type(index.ClaimType).Id(id).
+				FetchSource(source))
+		}
+		res, err := service.Do(ctx)
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		for _, doc := range res.Docs {
+			if doc == nil || !doc.Found || doc.Source == nil {
+				continue
+			}
+			claim := map[string]interface{}{}
+			if json.Unmarshal(*doc.Source, &claim) != nil {
+				continue
+			}
+			claims = append(claims, claim)
+		}
+	}
+	return claims, nil
+}
+
+// broker keeps the ring buffer of the latest events and fans new events out to the subscribers.
+type broker struct {
+	mu          sync.Mutex
+	buffer      []Event
+	next        int
+	seq         uint64
+	subscribers map[*Subscriber]bool
+	// clients counts the subscribers of each client.
+	clients map[string]int
+	stopped bool
+}
+
+func (b *broker) subscribe(filter Filter, client, lastEventID string) (*Subscriber, []Event, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return nil, nil, false, errors.Err("the server is shutting down")
+	}
+	if len(b.subscribers) >= MaxSubscribers {
+		return nil, nil, false, errors.Err("too many open streams")
+	}
+	if MaxPerClient > 0 && b.clients[client] >= MaxPerClient {
+		return nil, nil, false, ErrClientLimit
+	}
+	s := &Subscriber{filter: filter, client: client, queue: make(chan []Event, MaxPending), Closed: make(chan struct{})}
+	b.subscribers[s] = true
+	b.clients[client]++
+	metrics.StreamSubscribers.Set(float64(len(b.subscribers)))
+	if lastEventID == "" {
+		return s, nil, true, nil
+	}
+	after, ok := ParseID(lastEventID)
+	events := b.buffered()
+	caughtUp := ok && (len(events) == 0 && after <= b.seq || len(events) > 0 && after+1 >= events[0].Seq)
+	var backlog []Event
+	for _, e := range events {
+		if (!caughtUp || e.Seq > after) && filter.matches(e.Claim) {
+			backlog = append(backlog, e)
+		}
+	}
+	return s, backlog, caughtUp, nil
+}
+
+// buffered returns the events of the ring buffer, oldest first.
+func (b *broker) buffered() []Event {
+	if len(b.buffer) < BufferSize {
+		return append([]Event(nil), b.buffer...)
+	}
+	return append(append([]Event(nil), b.buffer[b.next:]...), b.buffer[:b.next]...)
+}
+
+func (b *broker) hasQueries() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		if s.filter.Query != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *broker) publish(claims []map[string]interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := make([]Event, 0, len(claims))
+	for _, claim := range claims {
+		b.seq++
+		e := Event{Seq: b.seq, Claim: claim}
+		events = append(events, e)
+		if len(b.buffer) < BufferSize {
+			b.buffer = append(b.buffer, e)
+		} else {
+			b.buffer[b.next] = e
+			b.next = (b.next + 1) % BufferSize
+		}
+	}
+	metrics.StreamEvents.Add(float64(len(events)))
+	for s := range b.subscribers {
+		var batch []Event
+		for _, e := range events {
+			if s.filter.matches(e.Claim) {
+				batch = append(batch, e)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		s.mu.Lock()
+		// A batch larger than MaxPending is still queued for a subscriber that has caught up.
+		full := s.pending > 0 && s.pending+len(batch) > MaxPending
+		if !full {
+			select {
+			case s.queue <- batch:
+				s.pending += len(batch)
+			default:
+				full = true
+			}
+		}
+		s.mu.Unlock()
+		if full {
+			b.removeLocked(s, true)
+		}
+	}
+}
+
+func (b *broker) remove(s *Subscriber, overflowed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(s, overflowed)
+}
+
+func (b *broker) removeLocked(s *Subscriber, overflowed bool) {
+	if !b.subscribers[s] {
+		return
+	}
+	delete(b.subscribers, s)
+	if b.clients[s.client]--; b.clients[s.client] <= 0 {
+		delete(b.clients, s.client)
+	}
+	metrics.StreamSubscribers.Set(float64(len(b.subscribers)))
+	if overflowed {
+		s.Overflowed = true
+		metrics.StreamOverflows.Inc()
+	}
+	close(s.Closed)
+}
+
+func (b *broker) shutdown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	for s := range b.subscribers {
+		b.removeLocked(s, false)
+	}
+}
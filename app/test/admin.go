@@ -0,0 +1,148 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/accesslog"
+	"github.com/lbryio/lighthouse/app/actions"
+	"github.com/lbryio/lighthouse/app/admin"
+
+	"github.com/sirupsen/logrus"
+)
+
+// auditedCall is an admin request and the entry expected for it in the audit log.
+type auditedCall struct {
+	requestID string
+	action    string
+	actor     string
+	outcome   string
+}
+
+// testAdmin registers an admin token, deletes and reindexes a claim through the admin api and checks that refused
+// and malformed requests are rejected, and that every request is recorded in the audit log.
+func testAdmin() {
+	var results []searchResult
+	status, body := get("/search", url.Values{"s": {alertsQuery}, "size": {"1"}})
+	if status != http.StatusOK || json.Unmarshal(body, &results) != nil || len(results) == 0 {
+		logrus.Fatalf("admin test needs claims matching %q: %d %s", alertsQuery, status, body)
+	}
+	claimID := results[0].ClaimID
+	run := strconv.FormatInt(time.Now().UnixNano(), 10)
+	token := "admin-test-" + run
+	admin.AddToken("admin-test", token)
+	defer admin.RemoveToken(token)
+	claims := actions.ClaimIDsRequest{ClaimIDs: []string{claimID}}
+	var calls []auditedCall
+
+	call := func(name, path, token, contentType string, body interface{}) (int, []byte) {
+		requestID := "admin-test-" + run + "-" + name
+		status, respBody := adminSend(path, token, requestID, contentType, body)
+		outcome := admin.OutcomeSuccess
+		actor := "token:admin-test"
+		if status >= 300 {
+			outcome = admin.OutcomeError
+		}
+		if status == http.StatusUnauthorized {
+			actor = ""
+		}
+		calls = append(calls, auditedCall{requestID: requestID, action: "POST " + path, actor: actor, outcome: outcome})
+		return status, respBody
+	}
+
+	if status, body := call("anonymous", "/admin/claims/delete", "", jsonContentType, claims); status != http.StatusUnauthorized {
+		logrus.Fatalf("deleting claims without an admin token returned %d: %s", status, body)
+	}
+	if status, body := call("unknown-token", "/admin/claims/delete", "not-"+token, jsonContentType, "not a claim ids request"); status != http.StatusUnauthorized {
+		logrus.Fatalf("a malformed request with an unknown admin token returned %d: %s", status, body)
+	}
+	invalid := actions.ClaimIDsRequest{ClaimIDs: []string{"not-a-claim-id"}}
+	if status, body := call("invalid", "/admin/claims/delete", token, jsonContentType, invalid); status != http.StatusBadRequest {
+		logrus.Fatalf("deleting an invalid claim id returned %d: %s", status, body)
+	}
+
+	var result actions.ClaimsResult
+	status, body = call("delete", "/admin/claims/delete", token, jsonContentType, claims)
+	if status != http.StatusOK || json.Unmarshal(body, &result) != nil || len(result.Removed) != 1 || result.Removed[0] != claimID {
+		logrus.Fatalf("deleting claim %s returned %d: %s", claimID, status, body)
+	}
+	// Sent without a content type, as with the curl examples of the readme which post the json as a form.
+	status, body = call("reindex", "/admin/claims/reindex", token, formContentType, claims)
+	if status != http.StatusOK || json.Unmarshal(body, &result) != nil || len(result.Indexed) != 1 || result.Indexed[0] != claimID {
+		logrus.Fatalf("reindexing claim %s returned %d: %s", claimID, status, body)
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(100 * time.Millisecond) {
+		missing := missingAuditEntries(token, calls)
+		if missing == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			logrus.Fatalf("the audit log does not record %+v", *missing)
+		}
+	}
+	logrus.Info("admin actions are authorized before they are parsed and recorded in the audit log")
+}
+
+// missingAuditEntries returns the first call not recorded in the latest entries of the audit log, nil if all are.
+func missingAuditEntries(token string, calls []auditedCall) *auditedCall {
+	req, err := http.NewRequest(http.MethodGet, apiURL+"/admin/audit?size=100", nil)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	req.Header.Set(admin.TokenHeader, "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logrus.Fatalf("GET /admin/audit failed with %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	var entries []admin.Entry
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &entries) != nil {
+		logrus.Fatalf("the audit log returned %d: %s", resp.StatusCode, body)
+	}
+	recorded := make(map[string]admin.Entry, len(entries))
+	for _, e := range entries {
+		recorded[e.RequestID] = e
+	}
+	for i, c := range calls {
+		e, ok := recorded[c.requestID]
+		if !ok || e.Action != c.action || e.Actor != c.actor || e.Outcome != c.outcome {
+			return &calls[i]
+		}
+	}
+	return nil
+}
+
+// adminSend posts the json body as the content type to the admin api with the request id, presenting the admin token
+// if it is not empty.
+func adminSend(path, token, requestID, contentType string, body interface{}) (int, []byte) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, apiURL+path, bytes.NewReader(data))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(accesslog.RequestIDHeader, requestID)
+	if token != "" {
+		req.Header.Set(admin.TokenHeader, "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logrus.Fatalf("POST %s failed with %s", path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logrus.Fatalf("POST %s failed with %s", path, err)
+	}
+	return resp.StatusCode, respBody
+}
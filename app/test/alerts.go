@@ -0,0 +1,177 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/alerts"
+	"github.com/lbryio/lighthouse/app/auth"
+
+	"github.com/sirupsen/logrus"
+)
+
+const alertsQuery = "interesting and amazing facts"
+
+type webhookCall struct {
+	header http.Header
+	body   []byte
+}
+
+// testAlerts saves a search, matches it against indexed claims and checks the signed alert reaches a local stand-in
+// for the webhook after a failed attempt, that the delivery log records both attempts and that claims are not
+// alerted on twice.
+func testAlerts() {
+	calls := make(chan webhookCall, 10)
+	failures := int32(1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		calls <- webhookCall{header: r.Header, body: body}
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer webhook.Close()
+	allowPrivate, backoff := alerts.AllowPrivateWebhooks, alerts.RetryBackoff
+	defer func() {
+		alerts.AllowPrivateWebhooks, alerts.RetryBackoff = allowPrivate, backoff
+	}()
+	alerts.AllowPrivateWebhooks = true
+	alerts.RetryBackoff = 100 * time.Millisecond
+	key := "alerts-test-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err := auth.Add(auth.Key{Name: "alerts-test", Key: key, Tier: "partner"})
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer auth.Remove(key)
+
+	var results []searchResult
+	status, body := get("/search", url.Values{"s": {alertsQuery}, "size": {"3"}})
+	if status != http.StatusOK || json.Unmarshal(body, &results) != nil || len(results) == 0 {
+		logrus.Fatalf("alerts test needs claims matching %q: %d %s", alertsQuery, status, body)
+	}
+	var claimIDs []string
+	for _, r := range results {
+		claimIDs = append(claimIDs, r.ClaimID)
+	}
+
+	request := map[string]interface{}{
+		"name":        "alerts test",
+		"params":      map[string]string{"s": alertsQuery},
+		"webhook_url": webhook.URL + "/hook",
+	}
+	if status, body := send(http.MethodPost, "/alerts", "", request); status != http.StatusUnauthorized {
+		logrus.Fatalf("saving a search without an api key returned %d: %s", status, body)
+	}
+	// Saved without a content type, as with the curl example of the readme which posts the json as a form.
+	status, body = sendAs(http.MethodPost, "/alerts", key, formContentType, request)
+	var saved alerts.SavedSearch
+	if status != http.StatusCreated || json.Unmarshal(body, &saved) != nil || saved.Secret == "" {
+		logrus.Fatalf("saving a search returned %d: %s", status, body)
+	}
+	defer send(http.MethodDelete, "/alerts/"+saved.ID, key, nil)
+
+	alerts.Match(claimIDs)
+	first, second := waitForCall(calls), waitForCall(calls)
+	if first.header.Get(alerts.DeliveryHeader) != second.header.Get(alerts.DeliveryHeader) {
+		logrus.Fatal("the retry of an alert was sent with another delivery id")
+	}
+	timestamp, _ := strconv.ParseInt(second.header.Get(alerts.TimestampHeader), 10, 64)
+	if second.header.Get(alerts.SignatureHeader) != alerts.Sign(saved.Secret, timestamp, second.body) {
+		logrus.Fatal("the alert signature does not match its body")
+	}
+	var payload alerts.Payload
+	err = json.Unmarshal(second.body, &payload)
+	if err != nil || payload.Search.ID != saved.ID || len(payload.Claims) == 0 || payload.Search.Secret != "" {
+		logrus.Fatalf("unexpected alert payload: %s", second.body)
+	}
+
+	var log []alerts.Delivery
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(100 * time.Millisecond) {
+		status, body = send(http.MethodGet, "/alerts/"+saved.ID+"/deliveries", key, nil)
+		if status != http.StatusOK || json.Unmarshal(body, &log) != nil {
+			logrus.Fatalf("the delivery log returned %d: %s", status, body)
+		}
+		if len(log) == 1 && log[0].Status == alerts.StatusDelivered {
+			break
+		}
+		if time.Now().After(deadline) {
+			logrus.Fatalf("the delivery log does not record the delivery: %s", body)
+		}
+	}
+	if attempts := log[0].Attempts; len(attempts) != 2 || attempts[0].StatusCode != http.StatusServiceUnavailable {
+		logrus.Fatalf("the delivery log does not record the failed attempt: %s", body)
+	}
+
+	alerts.Match(claimIDs)
+	select {
+	case call := <-calls:
+		logrus.Fatalf("claims were alerted on twice: %s", call.body)
+	case <-time.After(time.Second):
+	}
+
+	if status, body := send(http.MethodDelete, "/alerts/"+saved.ID, key, nil); status != http.StatusOK {
+		logrus.Fatalf("deleting the saved search returned %d: %s", status, body)
+	}
+	if status, body := send(http.MethodGet, "/alerts/"+saved.ID, key, nil); status != http.StatusNotFound {
+		logrus.Fatalf("the deleted saved search returned %d: %s", status, body)
+	}
+	logrus.Info("saved search alerts are delivered, signed, retried and logged")
+}
+
+func waitForCall(calls chan webhookCall) webhookCall {
+	select {
+	case call := <-calls:
+		return call
+	case <-time.After(10 * time.Second):
+		logrus.Fatal("the webhook was not called")
+	}
+	return webhookCall{}
+}
+
+const (
+	jsonContentType = "application/json"
+	// formContentType is the content type curl posts data with unless told otherwise.
+	formContentType = "application/x-www-form-urlencoded"
+)
+
+// send makes a request with a json body, presenting the api key if it is not empty.
+func send(method, path, key string, body interface{}) (int, []byte) {
+	return sendAs(method, path, key, jsonContentType, body)
+}
+
+// sendAs makes a request with a json body sent as the content type, presenting the api key if it is not empty.
+func sendAs(method, path, key, contentType string, body interface{}) (int, []byte) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, apiURL+path, bytes.NewReader(data))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if key != "" {
+		req.Header.Set(auth.APIKeyHeader, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logrus.Fatalf("%s %s failed with %s", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logrus.Fatalf("%s %s failed with %s", method, path, err)
+	}
+	return resp.StatusCode, respBody
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"context"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/reindex"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testIndexAliases checks that the claims are read and written through aliases of the same index, as a reindex left
+// unfinished would leave them on different indices.
+func testIndexAliases() {
+	read, write, err := es.ClaimsAliases(context.Background())
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if read == "" || read != write {
+		logrus.Fatalf("the %s alias points to %q and the %s alias to %q", index.Claims, read, index.ClaimsWrite, write)
+	}
+	if _, ok := index.Version(read); !ok {
+		logrus.Warnf("%s is not a versioned index, run lighthouse reindex --drop-legacy to replace it", read)
+	}
+	logrus.Infof("claims are read and written through the aliases of %s", read)
+}
+
+// testReindex builds the next version of the claims index from the serving one, checks that both aliases moved to it
+// and that it holds the same claims, then rolls back and checks that the aliases returned to the previous index.
+func testReindex() {
+	ctx := context.Background()
+	read, _, err := es.ClaimsAliases(ctx)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if _, ok := index.Version(read); !ok {
+		logrus.Warnf("skipping the reindex test, %s cannot be rolled back to", read)
+		return
+	}
+	before := countClaims(ctx, read)
+	// Catch up from a fixed time, whatever the sync state of the host running the tests records.
+	since := time.Now().Add(-time.Hour)
+	name, err := reindex.Run(ctx, reindex.Options{FromES: true, Since: since})
+	if err != nil {
+		logrus.Fatalf("reindexing from %s failed with %s", read, err)
+	}
+	defer func() {
+		_, err := es.Client.DeleteIndex(name).Do(ctx)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+	checkClaimsAliases(ctx, name)
+	if after := countClaims(ctx, name); name == read || after != before {
+		logrus.Fatalf("%s was reindexed into %s with %d claims instead of %d", read, name, after, before)
+	}
+
+	previous, err := reindex.Rollback(ctx, since)
+	if err != nil {
+		logrus.Fatalf("rolling back from %s failed with %s", name, err)
+	}
+	if previous != read {
+		logrus.Fatalf("rolling back from %s returned to %s instead of %s", name, previous, read)
+	}
+	checkClaimsAliases(ctx, read)
+	if after := countClaims(ctx, read); after != before {
+		logrus.Fatalf("%s has %d claims after the rollback instead of %d", read, after, before)
+	}
+	logrus.Infof("%s was reindexed into %s and rolled back", read, name)
+}
+
+// checkClaimsAliases fails the test unless both aliases of the claims point to the index.
+func checkClaimsAliases(ctx context.Context, name string) {
+	read, write, err := es.ClaimsAliases(ctx)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if read != name || write != name {
+		logrus.Fatalf("the %s alias points to %q and the %s alias to %q instead of %s", index.Claims, read,
+			index.ClaimsWrite, write, name)
+	}
+}
+
+func countClaims(ctx context.Context, name string) int64 {
+	_, err := es.Client.Refresh(name).Do(ctx)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	count, err := es.Client.Count(name).Do(ctx)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return count
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+type channelListing struct {
+	Channel    searchResult   `json:"channel"`
+	Claims     []searchResult `json:"claims"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// testChannelClaims indexes a channel with five streams, the last without a release time, and pages through its
+// listing a few streams at a time in each sort, checking that every stream is listed once in order and that cursors
+// are refused by the other sorts.
+func testChannelClaims() {
+	ctx := context.Background()
+	now := time.Now().UnixNano()
+	channelID := fmt.Sprintf("%032x%08d", now, 99)
+	bulk := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	cleanup := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	bulk.Add(elastic.NewBulkIndexRequest().Id(channelID).Doc(map[string]interface{}{
+		"claimId": channelID, "name": "@listing", "claim_type": "channel", "claim_cnt": 5,
+	}))
+	cleanup.Add(elastic.NewBulkDeleteRequest().Id(channelID))
+	var streams []string
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("%032x%08d", now, i)
+		streams = append(streams, id)
+		doc := map[string]interface{}{"claimId": id, "name": "listed-" + strconv.Itoa(i), "claim_type": "stream",
+			"channel_claim_id": channelID, "view_cnt": i + 1}
+		if i < 4 {
+			doc["release_time"] = fmt.Sprintf("2020-01-0%dT00:00:00Z", i+1)
+		}
+		bulk.Add(elastic.NewBulkIndexRequest().Id(id).Doc(doc))
+		cleanup.Add(elastic.NewBulkDeleteRequest().Id(id))
+	}
+	res, err := bulk.Do(ctx)
+	if err != nil || res.Errors {
+		logrus.Fatalf("indexing the channel to list failed with %v", err)
+	}
+	defer func() {
+		_, err := cleanup.Do(ctx)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	newest := strings.Join([]string{streams[3], streams[2], streams[1], streams[0], streams[4]}, ",")
+	if listed := listChannel(channelID, "release_time", 2); listed != newest {
+		logrus.Fatalf("the channel was listed newest first as %s instead of %s", listed, newest)
+	}
+	// Pages of one stream end with the stream without a release time, whose cursor holds the largest long.
+	if listed := listChannel(channelID, "^release_time", 1); listed != strings.Join(streams, ",") {
+		logrus.Fatalf("the channel was listed oldest first as %s instead of %s", listed, strings.Join(streams, ","))
+	}
+	if listed := listChannel(channelID, "trending", 2); len(strings.Split(listed, ",")) != len(streams) {
+		logrus.Fatalf("the channel was listed by trending as %s", listed)
+	}
+
+	status, body := get("/channel/"+channelID+"/claims", url.Values{"size": {"2"}})
+	var page channelListing
+	if status != http.StatusOK || json.Unmarshal(body, &page) != nil || page.NextCursor == "" {
+		logrus.Fatalf("listing the channel returned %d: %s", status, body)
+	}
+	params := url.Values{"size": {"2"}, "sort_by": {"view_cnt"}, "cursor": {page.NextCursor}}
+	if status, body := get("/channel/"+channelID+"/claims", params); status != http.StatusBadRequest {
+		logrus.Fatalf("a cursor of another sort returned %d: %s", status, body)
+	}
+	logrus.Info("channel listings page through every stream with cursors in each sort")
+}
+
+// listChannel pages through the streams of the channel size at a time in the sort, returning their comma separated
+// claim ids. Streams listed twice fail the test.
+func listChannel(channelID, sortBy string, size int) string {
+	var ids []string
+	seen := make(map[string]bool)
+	params := url.Values{"size": {strconv.Itoa(size)}, "sort_by": {sortBy}}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			logrus.Fatalf("listing the channel by %s did not end: %v", sortBy, ids)
+		}
+		status, body := get("/channel/"+channelID+"/claims", params)
+		var page channelListing
+		if status != http.StatusOK || json.Unmarshal(body, &page) != nil || page.Channel.ClaimID != channelID {
+			logrus.Fatalf("listing the channel by %s returned %d: %s", sortBy, status, body)
+		}
+		for _, claim := range page.Claims {
+			if seen[claim.ClaimID] {
+				logrus.Fatalf("listing the channel by %s returned %s twice", sortBy, claim.ClaimID)
+			}
+			seen[claim.ClaimID] = true
+			ids = append(ids, claim.ClaimID)
+		}
+		if page.NextCursor == "" {
+			return strings.Join(ids, ",")
+		}
+		params.Set("cursor", page.NextCursor)
+	}
+}
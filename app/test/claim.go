@@ -0,0 +1,84 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+// lookupBlockedChannel is on the static blocked list of channels.
+const lookupBlockedChannel = "565be843d5f231d37a037ee6d5276dc1618b5ca3"
+
+// testClaimLookups indexes two claims sharing a prefix and a claim of a blocked channel, and checks that /claim
+// answers a prefix matching both claims and the blocked claim with 404 while /claims lists them as missing.
+func testClaimLookups() {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("%016x", time.Now().UnixNano())
+	first, second := prefix+"a"+strings.Repeat("0", 23), prefix+"b"+strings.Repeat("0", 23)
+	blockedID := prefix + "c" + strings.Repeat("0", 23)
+	docs := map[string]map[string]interface{}{
+		first:  {"claimId": first, "name": "lookup-first", "claim_type": "stream"},
+		second: {"claimId": second, "name": "lookup-second", "claim_type": "stream"},
+		blockedID: {"claimId": blockedID, "name": "lookup-blocked", "claim_type": "stream",
+			"channel_claim_id": lookupBlockedChannel},
+	}
+	bulk := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	cleanup := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	for id, doc := range docs {
+		bulk.Add(elastic.NewBulkIndexRequest().Id(id).Doc(doc))
+		cleanup.Add(elastic.NewBulkDeleteRequest().Id(id))
+	}
+	res, err := bulk.Do(ctx)
+	if err != nil || res.Errors {
+		logrus.Fatalf("indexing the claims to look up failed with %v", err)
+	}
+	defer func() {
+		_, err := cleanup.Do(ctx)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	for id, expected := range map[string]int{
+		prefix:       http.StatusNotFound,
+		prefix + "a": http.StatusOK,
+		second:       http.StatusOK,
+		blockedID:    http.StatusNotFound,
+		prefix + "c": http.StatusNotFound,
+	} {
+		status, body := get("/claim/"+id, nil)
+		if status != expected {
+			logrus.Fatalf("/claim/%s returned %d instead of %d: %s", id, status, expected, body)
+		}
+	}
+	var claim searchResult
+	status, body := get("/claim/"+prefix+"a", nil)
+	if status != http.StatusOK || json.Unmarshal(body, &claim) != nil || claim.ClaimID != first {
+		logrus.Fatalf("/claim/%sa returned %d: %s", prefix, status, body)
+	}
+
+	var claims struct {
+		Claims  []searchResult `json:"claims"`
+		Missing []string       `json:"missing"`
+	}
+	ids := strings.Join([]string{prefix, prefix + "b", blockedID}, ",")
+	status, body = get("/claims", url.Values{"ids": {ids}})
+	if status != http.StatusOK || json.Unmarshal(body, &claims) != nil {
+		logrus.Fatalf("/claims?ids=%s returned %d: %s", ids, status, body)
+	}
+	if len(claims.Claims) != 1 || claims.Claims[0].ClaimID != second ||
+		strings.Join(claims.Missing, ",") != prefix+","+blockedID {
+		logrus.Fatalf("/claims?ids=%s returned %s", ids, body)
+	}
+	logrus.Info("claims are looked up by unique prefix, ambiguous prefixes and blocked claims are not found")
+}
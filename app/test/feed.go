@@ -0,0 +1,109 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+type feedPage struct {
+	Claims     []searchResult `json:"claims"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// feedStreams are the streams indexed for the feed test by channel, with the day of January 2020 they were released
+// on, 0 for a stream without a release time.
+var feedStreams = []struct {
+	channel int
+	day     int
+}{
+	{0, 5}, {0, 4}, {0, 3}, {1, 6}, {1, 1}, {1, 0},
+}
+
+// testChannelFeed indexes the streams of two channels and pages through their feed two streams at a time, checking
+// that the streams are merged newest first with the streams without a release time last, and that per_channel keeps
+// the latest streams of each channel across pages.
+func testChannelFeed() {
+	ctx := context.Background()
+	now := time.Now().UnixNano()
+	channels := []string{fmt.Sprintf("%032x%08d", now, 90), fmt.Sprintf("%032x%08d", now, 91)}
+	bulk := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	cleanup := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	var streams []string
+	for i, s := range feedStreams {
+		id := fmt.Sprintf("%032x%08d", now, i)
+		streams = append(streams, id)
+		doc := map[string]interface{}{"claimId": id, "name": fmt.Sprintf("feed-%d", i), "claim_type": "stream",
+			"channel_claim_id": channels[s.channel]}
+		if s.day > 0 {
+			doc["release_time"] = fmt.Sprintf("2020-01-0%dT00:00:00Z", s.day)
+		}
+		bulk.Add(elastic.NewBulkIndexRequest().Id(id).Doc(doc))
+		cleanup.Add(elastic.NewBulkDeleteRequest().Id(id))
+	}
+	res, err := bulk.Do(ctx)
+	if err != nil || res.Errors {
+		logrus.Fatalf("indexing the streams of the feed failed with %v", err)
+	}
+	defer func() {
+		_, err := cleanup.Do(ctx)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	expected := func(order ...int) string {
+		var ids []string
+		for _, i := range order {
+			ids = append(ids, streams[i])
+		}
+		return strings.Join(ids, ",")
+	}
+	if feed := pageFeed(channels, 0); feed != expected(3, 0, 1, 2, 4, 5) {
+		logrus.Fatalf("the feed was paged through as %s instead of %s", feed, expected(3, 0, 1, 2, 4, 5))
+	}
+	if feed := pageFeed(channels, 2); feed != expected(3, 0, 1, 4) {
+		logrus.Fatalf("the feed of 2 streams per channel was paged through as %s instead of %s", feed,
+			expected(3, 0, 1, 4))
+	}
+	if feed := pageFeed(channels, 1); feed != expected(3, 0) {
+		logrus.Fatalf("the feed of 1 stream per channel was paged through as %s instead of %s", feed, expected(3, 0))
+	}
+	logrus.Info("channel feeds merge the streams of the channels newest first and page through them with cursors")
+}
+
+// pageFeed pages through the feed of the channels two streams at a time, limited to perChannel streams of each
+// channel unless it is 0, and returns the comma separated claim ids of the streams.
+func pageFeed(channels []string, perChannel int) string {
+	var ids []string
+	request := map[string]interface{}{"channel_ids": channels, "size": 2}
+	if perChannel > 0 {
+		request["per_channel"] = perChannel
+	}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			logrus.Fatalf("paging through the feed did not end: %v", ids)
+		}
+		status, body := send(http.MethodPost, "/feed", "", request)
+		var page feedPage
+		if status != http.StatusOK || json.Unmarshal(body, &page) != nil {
+			logrus.Fatalf("the feed returned %d: %s", status, body)
+		}
+		for _, claim := range page.Claims {
+			ids = append(ids, claim.ClaimID)
+		}
+		if page.NextCursor == "" {
+			return strings.Join(ids, ",")
+		}
+		request["cursor"] = page.NextCursor
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+type graphQLResult struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type graphQLClaim struct {
+	ClaimID        string        `json:"claimId"`
+	ChannelClaimID string        `json:"channelClaimId"`
+	Channel        *graphQLClaim `json:"channel"`
+}
+
+// testGraphQL checks that the claims looked up at the same depth of a query are fetched in a single multi get, that
+// the channels of search results resolve to the channel they were published in and that a query is refused the
+// searches past its limit.
+func testGraphQL() {
+	var results []searchResult
+	status, body := get("/search", url.Values{"s": {alertsQuery}, "size": {"3"}})
+	if status != http.StatusOK || json.Unmarshal(body, &results) != nil || len(results) == 0 {
+		logrus.Fatalf("graphql test needs claims matching %q: %d %s", alertsQuery, status, body)
+	}
+
+	var lookups []string
+	for i, r := range results {
+		lookups = append(lookups, "c"+strconv.Itoa(i)+`: claim(id: "`+r.ClaimID+`") { claimId }`)
+	}
+	gets, fetched := claimBatches()
+	result := graphQL("{ " + strings.Join(lookups, " ") + " }")
+	if len(result.Errors) > 0 || len(result.Data) != len(results) {
+		logrus.Fatalf("looking up %d claims returned %+v", len(results), result)
+	}
+	for i, r := range results {
+		var claim graphQLClaim
+		if json.Unmarshal(result.Data["c"+strconv.Itoa(i)], &claim) != nil || claim.ClaimID != r.ClaimID {
+			logrus.Fatalf("claim %s was looked up as %s", r.ClaimID, result.Data["c"+strconv.Itoa(i)])
+		}
+	}
+	afterGets, afterFetched := claimBatches()
+	if afterGets-gets != 1 || afterFetched-fetched != float64(len(results)) {
+		logrus.Fatalf("looking up %d claims made %d multi gets of %v claims", len(results), afterGets-gets,
+			afterFetched-fetched)
+	}
+
+	result = graphQL(`{ search(s: ` + strconv.Quote(alertsQuery) + `, size: 10) {
+		claimId channelClaimId channel { claimId }
+	} }`)
+	var hits []graphQLClaim
+	if len(result.Errors) > 0 || json.Unmarshal(result.Data["search"], &hits) != nil || len(hits) == 0 {
+		logrus.Fatalf("searching with graphql returned %+v", result)
+	}
+	for _, hit := range hits {
+		if hit.Channel != nil && hit.Channel.ClaimID != hit.ChannelClaimID {
+			logrus.Fatalf("the channel of claim %s resolved to %s instead of %s", hit.ClaimID, hit.Channel.ClaimID,
+				hit.ChannelClaimID)
+		}
+	}
+
+	var searches []string
+	for i := 0; i <= 50; i++ {
+		searches = append(searches, "a"+strconv.Itoa(i)+`: autocomplete(s: "fact")`)
+	}
+	result = graphQL("{ " + strings.Join(searches, " ") + " }")
+	refused := 0
+	for _, e := range result.Errors {
+		if strings.Contains(e.Message, "at most 50 times") {
+			refused++
+		}
+	}
+	if refused != 1 {
+		logrus.Fatalf("a query making 51 searches was refused %d of them: %+v", refused, result.Errors)
+	}
+	logrus.Info("graphql batches claim lookups, resolves channels and limits the searches of a query")
+}
+
+// graphQL posts the query and returns its result, failing the test if it is not answered with 200.
+func graphQL(query string) graphQLResult {
+	status, body := send(http.MethodPost, "/graphql", "", map[string]string{"query": query})
+	var result graphQLResult
+	if status != http.StatusOK || json.Unmarshal(body, &result) != nil {
+		logrus.Fatalf("graphql query %s returned %d: %s", query, status, body)
+	}
+	return result
+}
+
+// claimBatches returns the number of multi gets of claims made so far and the number of claims they fetched.
+func claimBatches() (uint64, float64) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "lighthouse_elasticsearch_batch_size" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "type" && label.GetValue() == "claims" {
+					return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+	return 0, 0
+}
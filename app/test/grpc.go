@@ -0,0 +1,110 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/auth"
+	"github.com/lbryio/lighthouse/app/rpc"
+	"github.com/lbryio/lighthouse/app/rpc/pb"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testGRPC serves the grpc service on a local port and checks that Search returns the claims of /search, that
+// GetClaim looks up a claim and answers unknown claims with not found, and that ExportSearch streams the matches to
+// callers presenting an api key only.
+func testGRPC() {
+	var results []searchResult
+	httpStatus, body := get("/search", url.Values{"s": {alertsQuery}, "size": {"3"}})
+	if httpStatus != http.StatusOK || json.Unmarshal(body, &results) != nil || len(results) == 0 {
+		logrus.Fatalf("grpc test needs claims matching %q: %d %s", alertsQuery, httpStatus, body)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	server := rpc.NewServer()
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, listener.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		logrus.Fatalf("connecting to the grpc server failed with %s", err)
+	}
+	defer conn.Close()
+	client := pb.NewLighthouseClient(conn)
+
+	size := int32(len(results))
+	res, err := client.Search(ctx, &pb.SearchRequest{S: alertsQuery, Size: &size})
+	if err != nil || len(res.Claims) != len(results) {
+		logrus.Fatalf("grpc Search returned %v, %v", res, err)
+	}
+	for i, claim := range res.Claims {
+		if claim.ClaimId != results[i].ClaimID || claim.Name != results[i].Name {
+			logrus.Fatalf("grpc Search returned %s at %d where /search returned %s", claim.ClaimId, i,
+				results[i].ClaimID)
+		}
+	}
+
+	claim, err := client.GetClaim(ctx, &pb.GetClaimRequest{ClaimId: results[0].ClaimID})
+	if err != nil || claim.ClaimId != results[0].ClaimID {
+		logrus.Fatalf("grpc GetClaim of %s returned %v, %v", results[0].ClaimID, claim, err)
+	}
+	_, err = client.GetClaim(ctx, &pb.GetClaimRequest{ClaimId: "0000000000000000000000000000000000000000"})
+	if status.Code(err) != codes.NotFound {
+		logrus.Fatalf("grpc GetClaim of an unknown claim returned %v", err)
+	}
+
+	if exported, err := exportSearch(ctx, client, size); status.Code(err) != codes.PermissionDenied {
+		logrus.Fatalf("grpc ExportSearch without an api key returned %d claims, %v", len(exported), err)
+	}
+	key := "grpc-test-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err = auth.Add(auth.Key{Name: "grpc-test", Key: key, Tier: "partner"})
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer auth.Remove(key)
+	exported, err := exportSearch(metadata.AppendToOutgoingContext(ctx, "x-api-key", key), client, size)
+	if err != nil || len(exported) != len(results) {
+		logrus.Fatalf("grpc ExportSearch of %d claims returned %d claims, %v", len(results), len(exported), err)
+	}
+	for _, claim := range exported {
+		if claim.ClaimId == "" || claim.Name == "" {
+			logrus.Fatalf("grpc ExportSearch streamed an incomplete claim %v", claim)
+		}
+	}
+	logrus.Info("grpc searches, looks up claims and streams exports to api keys")
+}
+
+// exportSearch returns the claims streamed by ExportSearch for the size first matches of the alerts query.
+func exportSearch(ctx context.Context, client pb.LighthouseClient, size int32) ([]*pb.Claim, error) {
+	stream, err := client.ExportSearch(ctx, &pb.SearchRequest{S: alertsQuery, Size: &size})
+	if err != nil {
+		return nil, err
+	}
+	var claims []*pb.Claim
+	for {
+		claim, err := stream.Recv()
+		if err == io.EOF {
+			return claims, nil
+		}
+		if err != nil {
+			return claims, err
+		}
+		claims = append(claims, claim)
+	}
+}
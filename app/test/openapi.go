@@ -0,0 +1,194 @@
+package test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/actions"
+	"github.com/lbryio/lighthouse/app/admin"
+
+	"github.com/sirupsen/logrus"
+)
+
+const apiURL = "http://0.0.0.0:50005"
+
+// pathExample is substituted for the path parameters, it is valid as a claim id prefix.
+const pathExample = "abcd"
+
+type openAPIParameter struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   struct {
+		Type      string
+		MinLength int
+		Maximum   *float64
+		Enum      []interface{}
+	}
+}
+
+type openAPIDocument struct {
+	Paths map[string]map[string]struct {
+		Parameters []openAPIParameter
+		Responses  map[string]struct {
+			Content map[string]interface{}
+		}
+	}
+}
+
+// eventStream is the content type of endpoints streaming server-sent events, valid requests to them never end.
+const eventStream = "text/event-stream"
+
+// testOpenAPI checks that the published OpenAPI document has not drifted from the routes and the parameters the
+// handlers accept.
+func testOpenAPI() {
+	// The admin endpoints authorize requests before validating their parameters.
+	adminToken = "openapi-test-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	admin.AddToken("openapi-test", adminToken)
+	defer func() {
+		admin.RemoveToken(adminToken)
+		adminToken = ""
+	}()
+	var doc openAPIDocument
+	status, body := get("/openapi.json", nil)
+	if status != http.StatusOK {
+		logrus.Fatalf("openapi.json returned %d: %s", status, body)
+	}
+	err := json.Unmarshal(body, &doc)
+	if err != nil {
+		logrus.Fatalf("openapi.json is invalid: %s", err)
+	}
+	actions.GetRoutes().Each(func(route string, _ http.Handler) {
+		if _, ok := doc.Paths[route]; ok {
+			return
+		}
+		// Routes ending in a slash serve the paths below them, documented with path parameters.
+		for path := range doc.Paths {
+			if strings.HasSuffix(route, "/") && route != "/" && strings.HasPrefix(path, route) {
+				return
+			}
+		}
+		logrus.Fatalf("route %s is missing from openapi.json", route)
+	})
+
+	for specPath, operations := range doc.Paths {
+		op, ok := operations["get"]
+		if !ok || len(op.Parameters) == 0 {
+			continue
+		}
+		path := specPath
+		var query []openAPIParameter
+		for _, p := range op.Parameters {
+			if p.In == "path" {
+				path = strings.Replace(path, "{"+p.Name+"}", pathExample, 1)
+			} else {
+				query = append(query, p)
+			}
+		}
+		_, streamed := op.Responses["200"].Content[eventStream]
+		required := url.Values{}
+		for _, p := range query {
+			if p.Required {
+				required.Set(p.Name, example(p))
+			}
+		}
+		for _, p := range query {
+			params := copyValues(required)
+			params.Set(p.Name, example(p))
+			if !streamed {
+				if _, body := get(path, params); strings.Contains(string(body), "Extraneous params") {
+					logrus.Fatalf("%s does not accept the documented parameter %s: %s", path, p.Name, body)
+				}
+			}
+			if p.Schema.Maximum != nil {
+				params.Set(p.Name, strconv.Itoa(int(*p.Schema.Maximum)+1))
+				if status, body := get(path, params); status != http.StatusBadRequest {
+					logrus.Fatalf("%s accepted %s above its documented maximum with %d: %s", path, p.Name, status, body)
+				}
+			}
+			if p.Required {
+				params = copyValues(required)
+				params.Del(p.Name)
+				if status, body := get(path, params); status != http.StatusBadRequest {
+					logrus.Fatalf("%s accepted a request without the required %s with %d: %s", path, p.Name, status, body)
+				}
+			}
+		}
+		params := copyValues(required)
+		params.Set("undocumented_param", "1")
+		if _, body := get(path, params); !strings.Contains(string(body), "Extraneous params") {
+			logrus.Fatalf("%s accepts parameters that are not documented: %s", path, body)
+		}
+	}
+	logrus.Info("openapi.json matches the routes and their parameters")
+}
+
+// example returns a valid value for the parameter according to its schema.
+func example(p openAPIParameter) string {
+	if len(p.Schema.Enum) > 0 {
+		return toString(p.Schema.Enum[0])
+	}
+	switch p.Schema.Type {
+	case "boolean":
+		return "true"
+	case "integer", "number":
+		return "1"
+	}
+	s := "lbry"
+	for len(s) < p.Schema.MinLength {
+		s += s
+	}
+	return s
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func copyValues(values url.Values) url.Values {
+	c := url.Values{}
+	for k, v := range values {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// adminToken is presented as an admin token by get when it is set.
+var adminToken string
+
+func get(path string, params url.Values) (int, []byte) {
+	u := apiURL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if adminToken != "" {
+		req.Header.Set(admin.TokenHeader, "Bearer "+adminToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logrus.Fatalf("GET %s failed with %s", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logrus.Fatalf("GET %s failed with %s", u, err)
+	}
+	return resp.StatusCode, body
+}
@@ -0,0 +1,238 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/es/index"
+	"github.com/lbryio/lighthouse/app/jobs/chainquery"
+	"github.com/lbryio/lighthouse/app/jobs/source"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/olivere/elastic.v6"
+)
+
+const sourceChannel = "b9c3f4d6a1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6"
+
+// sourceHeights are the heights of the claims returned by the stand-in for claim_search, a page of claims at a single
+// height is followed by pages spanning several.
+var sourceHeights = []int{100, 100, 100, 101, 102}
+
+// testClaimSources pages through a local stand-in for the claim_search of the SDK and a file of claims, checking
+// that the claims are mapped the way Chainquery returns them and that both resume from their checkpoints, then syncs
+// the file into elasticsearch.
+func testClaimSources() {
+	var requests []map[string]interface{}
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string
+			Params map[string]interface{}
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if json.Unmarshal(body, &req) != nil || req.Method != "claim_search" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req.Params)
+		page := int(req.Params["page"].(float64))
+		size := int(req.Params["page_size"].(float64))
+		minHeight := 0
+		if height, ok := req.Params["height"].(string); ok {
+			minHeight, _ = strconv.Atoi(strings.TrimPrefix(height, ">="))
+		}
+		var matching []int
+		for i, height := range sourceHeights {
+			if height >= minHeight {
+				matching = append(matching, i)
+			}
+		}
+		var items []interface{}
+		for j := (page - 1) * size; j < page*size && j < len(matching); j++ {
+			i := matching[j]
+			items = append(items, map[string]interface{}{
+				"name":       "claim-" + strconv.Itoa(i),
+				"claim_id":   fmt.Sprintf("%040d", i),
+				"value_type": "stream",
+				"timestamp":  1600000000 + i,
+				"height":     sourceHeights[i],
+				"meta":       map[string]interface{}{"effective_amount": "1.5", "is_controlling": i == 0},
+				"signing_channel": map[string]interface{}{
+					"name": "@channel", "claim_id": sourceChannel,
+					"meta": map[string]interface{}{"effective_amount": "10", "claims_in_channel": 5},
+				},
+				"value": map[string]interface{}{
+					"title":        "Claim " + strconv.Itoa(i),
+					"release_time": "1500000000",
+					"tags":         []string{"science", "Mature"},
+					"source":       map[string]interface{}{"media_type": "video/mp4"},
+					"video":        map[string]interface{}{"width": 1920, "height": 1080, "duration": 60},
+					"fee":          map[string]interface{}{"amount": "0.5", "currency": "LBC"},
+				},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": map[string]interface{}{
+			"items": items, "page": page, "page_size": size, "total_pages": (len(matching) + size - 1) / size,
+			"total_items": len(matching),
+		}})
+	}))
+	defer rpc.Close()
+
+	ctx := context.Background()
+	since := time.Unix(1600000000, 0)
+	channelID := sourceChannel
+	src := source.NewRPC(rpc.URL)
+	first, err := src.Next(ctx, source.Request{Since: since, ChannelID: &channelID, Size: 2})
+	if err != nil || len(first.Claims) != 2 || first.Done || first.Checkpoint != "100:2" {
+		logrus.Fatalf("the first page of claim_search returned %+v: %v", first, err)
+	}
+	params := requests[0]
+	if params["timestamp"] != ">=1600000000" || params["channel_ids"].([]interface{})[0] != sourceChannel ||
+		params["height"] != nil {
+		logrus.Fatalf("claim_search was called with %v", params)
+	}
+	claim := first.Claims[0]
+	if claim.ClaimType.String != "stream" || claim.ChannelClaimID.String != sourceChannel ||
+		claim.EffectiveAmount != 150000000 || claim.CertificateAmount != 1000000000 ||
+		claim.BidState != "Controlling" || !claim.NSFW || claim.TagsStr.String != "science,Mature" ||
+		claim.ContentType.String != "video/mp4" || claim.Duration.Uint64 != 60 || claim.Fee.Float64 != 0.5 ||
+		claim.ReleaseTimeUnix.Uint64 != 1500000000 || claim.TransactionTimeUnix.Uint64 != 1600000000 ||
+		claim.JSONValue.IsNull() || claim.StrippedName != "claim0" {
+		logrus.Fatalf("the claim_search result was mapped to %s", claim.AsJSON())
+	}
+	ids := []string{first.Claims[0].ClaimID, first.Claims[1].ClaimID}
+	for batch := first; !batch.Done; {
+		batch, err = src.Next(ctx, source.Request{Since: since, Checkpoint: batch.Checkpoint, Size: 2})
+		if err != nil || len(requests) > 10 {
+			logrus.Fatalf("resuming claim_search from its checkpoint returned %+v: %v", batch, err)
+		}
+		for _, claim := range batch.Claims {
+			ids = append(ids, claim.ClaimID)
+		}
+	}
+	if params := requests[1]; params["height"] != ">=100" || params["page"] != 2.0 {
+		logrus.Fatalf("claim_search was resumed within a height with %v", params)
+	}
+	if len(ids) != len(sourceHeights) {
+		logrus.Fatalf("paging through claim_search by height returned %v", ids)
+	}
+	for i, id := range ids {
+		if id != fmt.Sprintf("%040d", i) {
+			logrus.Fatalf("paging through claim_search by height returned %v", ids)
+		}
+	}
+
+	file, err := ioutil.TempFile("", "claims-*.ndjson")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	lines := []string{
+		`{"name":"the-old","claimId":"old","modified_at":"2000-01-01T00:00:00Z"}`,
+		`{"name":"first","claimId":"first","tags":["a","b"],"release_time":"2020-09-13T12:26:40Z","value":{"title":"First"}}`,
+		``,
+		`{"name":"second","claimId":"second","channel_claim_id":"` + sourceChannel + `"}`,
+		`{"name":"third","claimId":"third"}`,
+	}
+	for _, line := range lines {
+		_, _ = file.WriteString(line + "\n")
+	}
+	_ = file.Close()
+	fileSource := source.NewFile(file.Name())
+	batch, err := fileSource.Next(ctx, source.Request{Since: since, Size: 2})
+	if err != nil || len(batch.Claims) != 2 || batch.Done || batch.Claims[0].ClaimID != "first" {
+		logrus.Fatalf("the first batch of the claims file returned %+v: %v", batch, err)
+	}
+	if c := batch.Claims[0]; c.TagsStr.String != "a,b" || c.ReleaseTimeUnix.Uint64 != 1600000000 || c.JSONValue.String != `{"title":"First"}` {
+		logrus.Fatalf("the claim of the file was read as %s", c.AsJSON())
+	}
+	batch, err = fileSource.Next(ctx, source.Request{Since: since, Checkpoint: batch.Checkpoint, Size: 2})
+	if err != nil || len(batch.Claims) != 1 || !batch.Done || batch.Claims[0].ClaimID != "third" {
+		logrus.Fatalf("resuming the claims file from its checkpoint returned %+v: %v", batch, err)
+	}
+	batch, err = fileSource.Next(ctx, source.Request{ChannelID: &channelID, Size: 10})
+	if err != nil || len(batch.Claims) != 1 || batch.Claims[0].ClaimID != "second" {
+		logrus.Fatalf("filtering the claims file by channel returned %+v: %v", batch, err)
+	}
+
+	// The claims of the file are synced the way the scheduled sync runs, from a sync state of their own. The sync is
+	// reserved before the source is swapped so that no other sync runs with it. The second sync only reads the claim
+	// appended to the file since the first.
+	stateDir, err := ioutil.TempDir("", "syncstate")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer os.RemoveAll(stateDir)
+	var published [][]string
+	previousSource, previousDir, previousIndexed := chainquery.Source, chainquery.SyncStateDir, chainquery.OnIndexed
+	for _, appended := range []string{"", `{"name":"fourth","claimId":"fourth"}`} {
+		err = appendLine(file.Name(), appended)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		run, err := chainquery.StartSync(nil)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		chainquery.Source, chainquery.SyncStateDir = fileSource, stateDir
+		chainquery.OnIndexed = func(claimIDs []string) { published = append(published, claimIDs) }
+		err = run()
+		chainquery.Source, chainquery.SyncStateDir, chainquery.OnIndexed = previousSource, previousDir, previousIndexed
+		if err != nil {
+			logrus.Fatalf("syncing the claims file failed with %s", err)
+		}
+	}
+	if len(published) != 2 || strings.Join(published[0], ",") != "old,first,second,third" || strings.Join(published[1], ",") != "fourth" {
+		logrus.Fatalf("the syncs of the claims file indexed %v", published)
+	}
+	synced := []string{"old", "first", "second", "third", "fourth"}
+	service := es.Client.MultiGet()
+	cleanup := es.Client.Bulk().Index(index.ClaimsWrite).Type(index.ClaimType).Refresh("wait_for")
+	for _, id := range synced {
+		service.Add(elastic.NewMultiGetItem().Index(index.Claims).Type(index.ClaimType).Id(id))
+		cleanup.Add(elastic.NewBulkDeleteRequest().Id(id))
+	}
+	res, err := service.Do(ctx)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	for i, doc := range res.Docs {
+		if doc == nil || !doc.Found {
+			logrus.Fatalf("claim %s of the claims file was not synced to elasticsearch", synced[i])
+		}
+	}
+	var indexed struct{ Tags []string }
+	if json.Unmarshal(*res.Docs[1].Source, &indexed) != nil || strings.Join(indexed.Tags, ",") != "a,b" {
+		logrus.Fatalf("claim first of the claims file was indexed as %s", *res.Docs[1].Source)
+	}
+	_, err = cleanup.Do(ctx)
+	if err != nil {
+		logrus.Error(err)
+	}
+	logrus.Info("claims are read from claim_search and files, resume from their checkpoints and only appended claims are synced again")
+}
+
+// appendLine appends the line to the file, if it is not empty.
+func appendLine(name, line string) error {
+	if line == "" {
+		return nil
+	}
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(line + "\n")
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lighthouse/app/stream"
+
+	"github.com/sirupsen/logrus"
+)
+
+// streamClient bounds the time streams are read for, so that missing events fail the test instead of hanging it.
+var streamClient = &http.Client{Timeout: 30 * time.Second}
+
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// testClaimStream publishes indexed claims to the claim stream and checks that they are sent as server-sent events,
+// that reconnecting clients catch up from their Last-Event-ID, that clients are limited in the streams they open
+// and that a client falling behind is sent an overflow event.
+func testClaimStream() {
+	var results []searchResult
+	status, body := get("/search", url.Values{"s": {alertsQuery}, "size": {"1"}})
+	if status != http.StatusOK || json.Unmarshal(body, &results) != nil || len(results) == 0 {
+		logrus.Fatalf("stream test needs claims matching %q: %d %s", alertsQuery, status, body)
+	}
+	claimID := results[0].ClaimID
+	maxPending, maxPerClient := stream.MaxPending, stream.MaxPerClient
+	defer func() {
+		stream.MaxPending, stream.MaxPerClient = maxPending, maxPerClient
+	}()
+
+	resp, events := openStream("")
+	stream.Publish([]string{claimID})
+	first := readEvent(events)
+	var claim searchResult
+	if first.event != "claim" || first.id == "" || json.Unmarshal([]byte(first.data), &claim) != nil || claim.ClaimID != claimID {
+		logrus.Fatalf("the stream sent %+v for claim %s", first, claimID)
+	}
+	_ = resp.Body.Close()
+
+	stream.Publish([]string{claimID})
+	resp, events = openStream(first.id)
+	missed := readEvent(events)
+	if missed.event != "claim" || missed.id == first.id {
+		logrus.Fatalf("reconnecting after %s sent %+v", first.id, missed)
+	}
+	_ = resp.Body.Close()
+	resp, events = openStream("unknown-1")
+	if reset := readEvent(events); reset.event != "reset" {
+		logrus.Fatalf("reconnecting after an unknown event id sent %+v", reset)
+	}
+	_ = resp.Body.Close()
+
+	// The client stops reading so that the stream blocks once the socket buffers are full, while claims are
+	// published until the stream is closed for falling behind, which frees the only stream the client can open.
+	stream.MaxPending = 1
+	stream.MaxPerClient = 1
+	var slow *http.Response
+	for deadline := time.Now().Add(10 * time.Second); slow == nil; time.Sleep(100 * time.Millisecond) {
+		slow, events = tryStream("")
+		if slow == nil && time.Now().After(deadline) {
+			logrus.Fatal("the closed streams of the client were not released")
+		}
+	}
+	defer slow.Body.Close()
+	batch := make([]string, 1000)
+	for i := range batch {
+		batch[i] = claimID
+	}
+	overflowed := false
+	for i := 0; i < 100 && !overflowed; i++ {
+		stream.Publish(batch)
+		probe, _ := tryStream("")
+		if probe != nil {
+			overflowed = true
+			_ = probe.Body.Close()
+		}
+	}
+	if !overflowed {
+		logrus.Fatal("a second stream of the client was accepted, or the slow stream never overflowed")
+	}
+	for {
+		e := readEvent(events)
+		if e.event == "overflow" {
+			break
+		}
+		if e.event != "claim" {
+			logrus.Fatalf("the slow stream sent %+v before the overflow event", e)
+		}
+	}
+	logrus.Info("claims are streamed, caught up on reconnection, limited per client and overflow slow clients")
+}
+
+// openStream opens the claim stream, failing the test if it is refused.
+func openStream(lastEventID string) (*http.Response, *bufio.Reader) {
+	resp, events := tryStream(lastEventID)
+	if resp == nil {
+		logrus.Fatal("the claim stream was refused")
+	}
+	return resp, events
+}
+
+// tryStream opens the claim stream, it returns nil if the client has too many streams open.
+func tryStream(lastEventID string) (*http.Response, *bufio.Reader) {
+	req, err := http.NewRequest(http.MethodGet, apiURL+"/stream", nil)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		logrus.Fatalf("GET /stream failed with %s", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		_ = resp.Body.Close()
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), eventStream) {
+		logrus.Fatalf("GET /stream returned %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readEvent returns the next event of the stream, skipping comments and the retry field.
+func readEvent(events *bufio.Reader) streamEvent {
+	var e streamEvent
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			logrus.Fatalf("reading the claim stream failed with %s", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && e.event != "":
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
@@ -32,4 +32,16 @@ func RunTests() {
 	}
 	logrus.Info(results)
 
+	testIndexAliases()
+	testReindex()
+	testOpenAPI()
+	testAlerts()
+	testClaimStream()
+	testAdmin()
+	testClaimSources()
+	testGraphQL()
+	testGRPC()
+	testClaimLookups()
+	testChannelClaims()
+	testChannelFeed()
 }
@@ -0,0 +1,173 @@
+package timeout
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/api"
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// TimedOutHeader is set on responses built from searches that timed out on some shards.
+	TimedOutHeader = "X-Timed-Out"
+	// PartialHeader is set on responses built from searches that did not complete on every shard.
+	PartialHeader = "X-Partial-Results"
+)
+
+var (
+	// Routes holds the deadline of requests by route, routes with a deadline of 0 have none.
+	Routes = map[string]time.Duration{
+		"/search":       5 * time.Second,
+		"/search.rss":   5 * time.Second,
+		"/search.atom":  5 * time.Second,
+		"/autocomplete": 2 * time.Second,
+		"/status":       10 * time.Second,
+		"/stream":       0,
+	}
+	// Default is the deadline of requests to routes without an entry in Routes.
+	Default = 10 * time.Second
+	// ShardRatio is the share of the time left before the deadline that elasticsearch shards are given to search,
+	// leaving the rest to gather their partial results and answer before the request times out.
+	ShardRatio = 0.8
+)
+
+// ParseRoutes parses a list of route deadlines in the form `/route=2s;/route2=500ms` and applies them over the
+// defaults.
+func ParseRoutes(timeouts string) {
+	for _, t := range strings.Split(timeouts, ";") {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			logrus.Warningf("ignoring invalid timeout %q", t)
+			continue
+		}
+		Routes[strings.TrimSpace(parts[0])] = d
+	}
+}
+
+type contextKey struct{}
+
+type flags struct {
+	mu       sync.Mutex
+	timedOut bool
+	partial  bool
+}
+
+// Handler sets the deadline of each request from its route, and the timed out and partial results headers of
+// responses built from incomplete searches.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := Routes[strings.TrimRight(r.URL.Path, "/")]
+		if !ok {
+			d = Default
+		}
+		ctx := r.Context()
+		if d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		f := &flags{}
+		ctx = context.WithValue(ctx, contextKey{}, f)
+		h.ServeHTTP(&recorder{ResponseWriter: w, flags: f}, r.WithContext(ctx))
+	})
+}
+
+// Track returns a context in which SetPartial records whether the search was incomplete, and a function reporting
+// it, for requests that are not served through Handler.
+func Track(ctx context.Context) (context.Context, func() (partial, timedOut bool)) {
+	f := &flags{}
+	return context.WithValue(ctx, contextKey{}, f), func() (bool, bool) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.partial, f.timedOut
+	}
+}
+
+// SetPartial marks the response for the request as built from an incomplete search.
+func SetPartial(r *http.Request, timedOut bool) {
+	f, ok := r.Context().Value(contextKey{}).(*flags)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.partial = true
+	f.timedOut = f.timedOut || timedOut
+}
+
+// Shards returns the search timeout to pass to elasticsearch for the time left before the deadline of ctx, and false
+// if ctx has no deadline.
+func Shards(ctx context.Context) (string, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", false
+	}
+	ms := int64(float64(time.Until(deadline)) * ShardRatio / float64(time.Millisecond))
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10) + "ms", true
+}
+
+// Error returns a 504 status error if err is the result of a deadline being exceeded, and err otherwise.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if stderrors.Is(errors.Unwrap(err), context.DeadlineExceeded) {
+		return api.StatusError{Status: http.StatusGatewayTimeout, Err: errors.Err("elasticsearch did not answer in time")}
+	}
+	return err
+}
+
+// recorder adds the headers for the flags before the status is written. Responses from incomplete searches must not
+// be cached as a retry can return the complete results.
+type recorder struct {
+	http.ResponseWriter
+	flags *flags
+	wrote bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.wrote = true
+		r.flags.mu.Lock()
+		if r.flags.partial {
+			r.Header().Set(PartialHeader, "true")
+			r.Header().Set("Cache-Control", "no-store")
+		}
+		if r.flags.timedOut {
+			r.Header().Set(TimedOutHeader, "true")
+		}
+		r.flags.mu.Unlock()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) Flush() {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,175 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/lbryio/lighthouse/meta"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer delegates to the global provider so spans started before Init are exported once it is configured.
+var tracer = otel.Tracer("github.com/lbryio/lighthouse")
+
+var provider *sdktrace.TracerProvider
+
+// Init configures where spans are exported to. The exporter can be `stdout`, `file` to write to the file at
+// endpoint, or `otlp` to send them over http to the collector at endpoint. Tracing is disabled when it is empty or
+// `none`.
+func Init(exporter, endpoint string, sampleRatio float64) error {
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", "none":
+		return nil
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		var f *os.File
+		f, err = os.OpenFile(endpoint, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.Err(err)
+		}
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithInsecure()}
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+		exp, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return errors.Err("unknown tracing exporter %s, can only be stdout, file, otlp or none", exporter)
+	}
+	if err != nil {
+		return errors.Err(err)
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String("lighthouse"),
+			semconv.ServiceVersionKey.String(meta.GetVersion()))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	logrus.Infof("tracing enabled, exporting spans to %s", exporter)
+	return nil
+}
+
+// Shutdown exports any spans still buffered.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return errors.Err(provider.Shutdown(ctx))
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Detach returns a context that carries the span of ctx but is never canceled, for work that outlives the request.
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
+
+// Handler starts a span for each request named after the route pattern of the mux that serves it, continuing any
+// trace propagated by the caller.
+func Handler(mux *http.ServeMux) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, route := mux.Handler(r)
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, "HTTP "+r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(r.Method),
+					semconv.HTTPRouteKey.String(route),
+					semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+				))
+			defer span.End()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(rec, r.WithContext(ctx))
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rec.status))
+			if rec.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, strconv.Itoa(rec.status))
+			}
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Transport returns a round tripper that records a client span for each request made through base, such as the
+// requests of the elasticsearch client.
+func Transport(service string, base http.RoundTripper) http.RoundTripper {
+	return &transport{service: service, base: base}
+}
+
+type transport struct {
+	service string
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(r.Context(), t.service+" "+r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.PeerServiceKey.String(t.service),
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPURLKey.String(r.URL.String()),
+		))
+	defer span.End()
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+	res, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return res, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, res.Status)
+	}
+	return res, nil
+}
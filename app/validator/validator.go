@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"regexp"
 	"strings"
 
 	"github.com/lbryio/lbry.go/extras/util"
@@ -23,4 +24,15 @@ var (
 		}
 		return true
 	}, "invalid claim type, can only be "+strings.Join(possibleMediaTypes, ","))
+	// ClaimIDsValidator is used to validate comma separated lists of claim ids or claim id prefixes
+	ClaimIDsValidator = v.NewStringRule(func(str string) bool {
+		for _, id := range strings.Split(str, ",") {
+			if !claimID.MatchString(strings.TrimSpace(id)) {
+				return false
+			}
+		}
+		return true
+	}, "invalid claim id, must be up to 40 hexadecimal characters")
+
+	claimID = regexp.MustCompile(`^[0-9a-fA-F]{1,40}$`)
 )
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lbryio/lighthouse/app"
+	"github.com/lbryio/lighthouse/app/config"
+	"github.com/lbryio/lighthouse/app/es"
+	"github.com/lbryio/lighthouse/app/reindex"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	reindexCmd.Flags().Bool("from-es", false, "copy the serving index with the elasticsearch _reindex API instead of reading every claim from the claim source")
+	reindexCmd.Flags().Float64("tolerance", 0.01, "fraction of the claims of the serving index the new index may lack")
+	reindexCmd.Flags().Bool("drop-legacy", false, "allow deleting the claims index created before the aliases when the new index replaces it")
+	reindexCmd.Flags().Bool("keep-failed", false, "keep the new index if the reindex fails")
+	reindexCmd.Flags().Bool("rollback", false, "move the aliases back to the previous claims index")
+	reindexCmd.Flags().String("since", "", "catch up on the claims modified since this RFC 3339 time instead of the last claim sync recorded in the sync state")
+	rootCmd.AddCommand(reindexCmd)
+}
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Builds a new claims index and swaps it in without downtime",
+	Long: `Builds the next version of the claims index from the claim source, or from the serving index with --from-es,
+catches it up on the claims modified meanwhile, validates it and moves the read and write aliases to it. The
+previous index is kept, --rollback moves the aliases back to it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config.InitializeConfiguration()
+		app.InitElasticSearch()
+		defer es.Client.Stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-interrupt
+			// A second signal kills the process.
+			signal.Stop(interrupt)
+			logrus.Warn("reindex interrupted, cleaning up")
+			cancel()
+		}()
+
+		flags := cmd.Flags()
+		var since time.Time
+		if s, _ := flags.GetString("since"); s != "" {
+			var err error
+			since, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				logrus.Fatalf("invalid --since: %s", err)
+			}
+		}
+		var name string
+		var err error
+		if rollback, _ := flags.GetBool("rollback"); rollback {
+			name, err = reindex.Rollback(ctx, since)
+		} else {
+			opts := reindex.Options{Since: since}
+			opts.FromES, _ = flags.GetBool("from-es")
+			opts.Tolerance, _ = flags.GetFloat64("tolerance")
+			opts.DropLegacy, _ = flags.GetBool("drop-legacy")
+			opts.KeepFailed, _ = flags.GetBool("keep-failed")
+			name, err = reindex.Run(ctx, opts)
+		}
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+		logrus.Infof("%s serves the claims", name)
+	},
+}
@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"os"
+	"time"
+
 	"github.com/lbryio/lighthouse/app"
 	"github.com/lbryio/lighthouse/app/actions"
 	"github.com/lbryio/lighthouse/app/config"
 	"github.com/lbryio/lighthouse/app/jobs"
+	"github.com/lbryio/lighthouse/app/tracing"
 	"github.com/pkg/profile"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -13,9 +19,15 @@ import (
 func init() {
 	serveCmd.PersistentFlags().StringP("host", "", "0.0.0.0", "host to listen on")
 	serveCmd.PersistentFlags().IntP("port", "p", 50005, "port binding used for the api server")
+	serveCmd.PersistentFlags().Int("grpcport", 0, "port binding used for the grpc server, 0 disables it")
+	serveCmd.PersistentFlags().Int("adminport", 0, "port binding used for the mutual TLS admin server, 0 disables it")
+	serveCmd.PersistentFlags().Duration("shutdowntimeout", 30*time.Second, "how long to wait for in-flight requests to finish on shutdown")
 	//Bind to Viper
 	viper.BindPFlag("host", serveCmd.PersistentFlags().Lookup("host"))
 	viper.BindPFlag("port", serveCmd.PersistentFlags().Lookup("port"))
+	viper.BindPFlag("grpcport", serveCmd.PersistentFlags().Lookup("grpcport"))
+	viper.BindPFlag("adminport", serveCmd.PersistentFlags().Lookup("adminport"))
+	viper.BindPFlag("shutdowntimeout", serveCmd.PersistentFlags().Lookup("shutdowntimeout"))
 	rootCmd.AddCommand(serveCmd)
 }
 
@@ -32,6 +44,18 @@ var serveCmd = &cobra.Command{
 		actions.AutoUpdateCommand = "" //config.GetAutoUpdateCommand()
 		//Background Cron Jobs
 		jobs.Start()
-		app.DoYourThing()
+		err := app.DoYourThing()
+		jobs.Shutdown()
+		actions.ShutdownAdmin()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if tracingErr := tracing.Shutdown(ctx); tracingErr != nil {
+			logrus.Error(tracingErr)
+		}
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+		logrus.Info("lighthouse shut down gracefully")
 	},
 }
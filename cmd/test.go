@@ -12,6 +12,7 @@ import (
 	"github.com/lbryio/lighthouse/app/actions"
 	"github.com/lbryio/lighthouse/app/config"
 	"github.com/pkg/profile"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -42,7 +43,10 @@ var testCmd = &cobra.Command{
 			panic(err)
 		}
 		for _, c := range channels {
-			chainquery.Sync(&c)
+			err := chainquery.Sync(&c)
+			if err != nil {
+				logrus.Error(err)
+			}
 		}
 
 		go app.DoYourThing()